@@ -1,19 +1,45 @@
 package cors
 
 import (
-	"net/url"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // Config defines the configuration options for the CORS middleware
 type Config struct {
+	// Next defines a function to skip this middleware when it returns true
+	// Skipped requests are passed to the next handler without any CORS processing
+	Next func(c *fiber.Ctx) bool
+
+	// SkipPaths lists request paths that bypass the middleware like Next, matched with path.Match
+	// A pattern ending in "/**" also matches everything below that prefix, e.g. "/internal/**"
+	SkipPaths []string
+
 	// AllowOrigins is a comma-separated list of origins that are allowed to access the resource
-	// Use * to allow all origins, but note that * cannot be used with AllowCredentials=true
+	// Default is empty, which allows no origins; * allows all origins like AllowAllOrigins
+	// Entries may use a wildcard as the leftmost host label or as the port, e.g.
+	// "https://*.domain.com" (any subdomain, not domain.com itself) or "http://localhost:*" (any port)
 	AllowOrigins string
 
+	// AllowAllOrigins allows every origin (except "null", see AllowNullOrigin)
+	// It cannot be used with AllowCredentials=true
+	AllowAllOrigins bool
+
+	// AllowOriginPatterns lists regular expressions matched against the lowercase request origin,
+	// e.g. `https://tenant-[a-z0-9]+\.eu\.domain\.com`, checked after AllowOrigins
+	// Patterns are anchored automatically and must not be able to match arbitrary hosts,
+	// so "." must be escaped or replaced by a character class and the host must end in a
+	// literal domain of at least two labels, such as `\.domain\.com`
+	AllowOriginPatterns []string
+
 	// AllowCredentials indicates whether the response to the request can be exposed when the credentials flag is true
 	AllowCredentials bool
 
@@ -26,158 +52,865 @@ type Config struct {
 	// AllowMethods is a comma-separated list of HTTP methods that are allowed for CORS requests
 	AllowMethods string
 
+	// Origins, Headers, Methods and Expose are slice forms of AllowOrigins, AllowHeaders,
+	// AllowMethods and ExposeHeaders that take precedence when set
+	// Entries are normalized (origins lowercased, methods uppercased, headers canonicalized)
+	// and duplicates are dropped
+	Origins []string
+	Headers []string
+	Methods []string
+	Expose  []string
+
 	// MaxAge indicates how long (in seconds) the results of a preflight request can be cached
 	// Default is 0, which means each preflight request performs a new OPTIONS request
 	MaxAge int
+
+	// AllowOriginsFunc is consulted for origins that are not matched by AllowOrigins
+	// It reports whether the origin is allowed; a non-nil error is passed to Fiber's error handler
+	// and the request is not processed any further
+	AllowOriginsFunc func(origin string, c *fiber.Ctx) (bool, error)
+
+	// Logger records the middleware's decision for every request with an Origin header, e.g.
+	// slog.Default(). Rejected requests are logged at slog.LevelInfo and allowed requests at
+	// slog.LevelDebug, so allowed traffic is only logged when the handler enables debug level
+	// Default is nil, which disables logging
+	Logger *slog.Logger
+
+	// LogSampling logs only one in every LogSampling decisions, to bound the log volume
+	// during floods of rejected requests; decisions at disabled levels are not counted
+	// Default is 0, which logs every decision
+	LogSampling int
+
+	// Metrics counts the middleware's decisions for requests with an Origin header, e.g. a
+	// PrometheusMetrics; it is called before the hooks below
+	Metrics Metrics
+
+	// OnAllowed, OnRejected and OnPreflight are called with the middleware's decision for
+	// requests with an Origin header, e.g. to record audit events or alert on unexpected
+	// origins. OnPreflight is called for every preflight, before OnAllowed or OnRejected.
+	// A non-nil error stops processing the request and is passed to Fiber's error handler.
+	//
+	// For rejected preflights the response status is set to PreflightFailureStatus with
+	// StrictPreflight, or 204 otherwise, before OnRejected is called; OnRejected may replace
+	// the status and write a body, e.g. c.Status(fiber.StatusForbidden).JSON(...), to customize
+	// the response, unless PreflightContinue passes the preflight on to the route
+	OnAllowed   func(c *fiber.Ctx, d Decision) error
+	OnRejected  func(c *fiber.Ctx, d Decision) error
+	OnPreflight func(c *fiber.Ctx, d Decision) error
+
+	// OriginStore is consulted for origins that are not matched by AllowOrigins or
+	// AllowOriginPatterns, before AllowOriginsFunc, with the normalized origin and the
+	// request's user context; a non-nil error is handled as for AllowOriginsFunc
+	// Wrap stores that are slow to query, such as databases, with NewCachedStore
+	OriginStore OriginStore
+
+	// StrictPreflight makes preflight requests succeed only when the origin is allowed, the
+	// Access-Control-Request-Method is listed in AllowMethods and every header in
+	// Access-Control-Request-Headers is listed in AllowHeaders (or is a safe header when
+	// AllowHeaders is empty). Failed preflights get no CORS headers and PreflightFailureStatus
+	// Default is false, which answers every preflight with 204 No Content
+	StrictPreflight bool
+
+	// PreflightFailureStatus is the status code sent for preflights rejected by StrictPreflight
+	// Default is 403 Forbidden
+	PreflightFailureStatus int
+
+	// OptionsPassthrough passes OPTIONS requests that are not preflights (no
+	// Access-Control-Request-Method header) on to the next handler after setting CORS headers,
+	// leaving the status and body to the application's OPTIONS routes
+	// Default is false, which answers them with 204 No Content
+	OptionsPassthrough bool
+
+	// PreflightContinue passes successful preflight requests on to the next handler after
+	// setting CORS headers instead of answering them with 204 No Content
+	// Preflights rejected by StrictPreflight are still answered by the middleware
+	PreflightContinue bool
+
+	// EchoHeaderAllowlist adds headers to the built-in list of safe headers that are echoed back
+	// from Access-Control-Request-Headers when AllowHeaders is empty, e.g. "X-Tenant-Id"
+	EchoHeaderAllowlist []string
+
+	// EchoHeaderDenylist removes headers from the echo list; it takes precedence over the allowlist
+	EchoHeaderDenylist []string
+
+	// StrictEchoHeaders never echoes requested headers that are not on the echo list
+	// Default is false, which echoes the requested headers when none of them are on the list,
+	// except for denylisted and forbidden headers (Host, Cookie, Proxy-*, Sec-* and the
+	// rest of the Fetch standard's forbidden request headers), which are never echoed
+	StrictEchoHeaders bool
+
+	// AllowNullOrigin allows requests with "Origin: null", sent by sandboxed iframes, file://
+	// pages and some cross-origin redirects, and echoes "null" back
+	// Default is false; the null origin is never allowed by * or by an empty AllowOrigins
+	// It is ignored with ResponseWildcard, which sends "*" to every origin including "null"
+	AllowNullOrigin bool
+
+	// ResponseMode selects how allowed origins are written to Access-Control-Allow-Origin
+	// Default is ResponseEchoOrigin
+	ResponseMode ResponseMode
+
+	// LegacyEmptyOriginHeaders sets Access-Control-Allow-Credentials, -Methods, -Headers,
+	// -Expose-Headers and -Max-Age on requests without an Origin header, as older versions did
+	// Default is false: requests without an Origin are not CORS requests and get no CORS headers
+	LegacyEmptyOriginHeaders bool
+
+	// AllowPrivateNetwork answers Private Network Access preflights (those sent with
+	// Access-Control-Request-Private-Network: true) from allowed origins with
+	// Access-Control-Allow-Private-Network: true, letting public sites reach private addresses
+	AllowPrivateNetwork bool
+
+	// PrivateNetworkOrigins restricts AllowPrivateNetwork to these origins, which must also be
+	// allowed by AllowOrigins; entries use the same syntax as AllowOrigins
+	// Default is empty, which grants Private Network Access to every allowed origin
+	PrivateNetworkOrigins []string
+}
+
+// ResponseMode selects how Access-Control-Allow-Origin is written for allowed origins
+type ResponseMode int
+
+const (
+	// ResponseEchoOrigin echoes the request origin and adds Vary: Origin
+	ResponseEchoOrigin ResponseMode = iota
+
+	// ResponseWildcard sends a literal "*" on every response, with or without an Origin header,
+	// so CDNs can cache one response for all origins. Unset AllowMethods, AllowHeaders and
+	// ExposeHeaders are also sent as "*" instead of the defaults and echoed request headers.
+	// It requires AllowAllOrigins (or * in AllowOrigins) and AllowCredentials=false, since
+	// browsers treat "*" literally only for requests without credentials; note that "*" in
+	// Access-Control-Allow-Headers does not cover Authorization, which must be listed explicitly.
+	// Private Network Access is only granted when PrivateNetworkOrigins is empty and
+	// AllowNullOrigin is ignored, so "Origin: null" also gets "*", since the origin is not
+	// inspected: a response that depended on it could not be cached for all origins.
+	ResponseWildcard
+)
+
+// String returns the name used for the mode by LoadConfig and ConfigFromEnv
+func (m ResponseMode) String() string {
+	switch m {
+	case ResponseEchoOrigin:
+		return "echo"
+	case ResponseWildcard:
+		return "wildcard"
+	}
+	return "ResponseMode(" + strconv.Itoa(int(m)) + ")"
 }
 
+// MarshalText implements encoding.TextMarshaler
+func (m ResponseMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for "echo" and "wildcard"
+func (m *ResponseMode) UnmarshalText(text []byte) error {
+	switch strings.ToLower(string(text)) {
+	case "echo":
+		*m = ResponseEchoOrigin
+	case "wildcard":
+		*m = ResponseWildcard
+	default:
+		return fmt.Errorf("%w %q", ErrUnknownResponseMode, text)
+	}
+	return nil
+}
+
+// defaultMethods is sent in Access-Control-Allow-Methods when AllowMethods is not configured
+const defaultMethods = "GET, POST, HEAD, OPTIONS"
+
 // New creates a new CORS middleware handler
+// It panics if the configuration is invalid; use NewWithError to handle the error instead
 func New(config Config) fiber.Handler {
-	allowedOriginsMap := make(map[string]bool)
-	allowAll := false
-
-	// Parse allowed origins
-	if config.AllowOrigins != "" {
-		origins := strings.Split(config.AllowOrigins, ",")
-		for _, origin := range origins {
-			origin = strings.TrimSpace(origin)
-			if origin == "*" {
-				allowAll = true
-				break
-			}
-			// Add to allowed origins (normalized to lowercase)
-			if origin != "" {
-				// Normalize the origin by converting scheme and host to lowercase
-				if u, err := url.Parse(origin); err == nil {
-					u.Scheme = strings.ToLower(u.Scheme)
-					u.Host = strings.ToLower(u.Host)
-					allowedOriginsMap[u.String()] = true
-				} else {
-					// Fallback if parsing fails
-					allowedOriginsMap[strings.ToLower(origin)] = true
-				}
-			}
+	handler, err := NewWithError(config)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+// NewWithError creates a new CORS middleware handler
+// If the configuration is invalid it returns a *ConfigError listing every problem found
+func NewWithError(config Config) (fiber.Handler, error) {
+	p, err := compile(config)
+	if err != nil {
+		return nil, err
+	}
+	return p.handle, nil
+}
+
+// Validate checks the configuration and returns a *ConfigError listing every problem found,
+// or nil if the configuration can be used with New
+func (config Config) Validate() error {
+	_, err := compile(config)
+	return err
+}
+
+// policy is the parsed and validated form of a Config used by the middleware handler
+type policy struct {
+	config Config
+
+	// allowAll is set by AllowAllOrigins or a * entry in AllowOrigins
+	allowAll bool
+
+	// origins holds the exact and wildcard entries from AllowOrigins
+	origins originSet
+
+	// originRegexps holds the compiled AllowOriginPatterns
+	originRegexps []*regexp.Regexp
+
+	// privateNetworkOrigins restricts AllowPrivateNetwork to these origins when not empty
+	privateNetworkOrigins originSet
+
+	// echoHeaders are the lowercase headers that may be echoed when AllowHeaders is empty
+	// deniedHeaders are never echoed
+	echoHeaders   map[string]bool
+	deniedHeaders map[string]bool
+
+	// allowedMethods and allowedHeaders are used by StrictPreflight to check the requested
+	// method and headers; allowedHeaders keys are lowercase
+	allowedMethods map[string]bool
+	allowedHeaders map[string]bool
+	allowAnyMethod bool
+	allowAnyHeader bool
+
+	// failureStatus is the status code sent for preflights rejected by StrictPreflight
+	failureStatus int
+
+	// observed is set when decisions are logged or passed to Metrics or hooks
+	observed bool
+
+	// logCount counts the logged decisions for LogSampling
+	logCount atomic.Uint64
+
+	// allowMethods, allowHeaders, exposeHeaders and maxAge are the rendered header values and
+	// vary and preflightVary the Vary values for a response without one, all precomputed so
+	// the handler sets them without converting or formatting anything per request
+	allowMethods  []byte
+	allowHeaders  []byte
+	exposeHeaders []byte
+	maxAge        []byte
+	vary          []byte
+	preflightVary []byte
+}
+
+// skipped reports whether the request bypasses the middleware because of Next or SkipPaths
+func (p *policy) skipped(c *fiber.Ctx) bool {
+	if p.config.Next != nil && p.config.Next(c) {
+		return true
+	}
+	if len(p.config.SkipPaths) == 0 {
+		return false
+	}
+	requestPath := c.Path()
+	for _, pattern := range p.config.SkipPaths {
+		if matchPath(pattern, requestPath) {
+			return true
 		}
 	}
+	return false
+}
 
-	// Validate configuration
-	if config.AllowCredentials && allowAll {
-		// According to spec section 3.2.5: If credentials mode is "include",
-		// then Access-Control-Allow-Origin cannot be *
-		panic("CORS: AllowCredentials=true is incompatible with AllowOrigins=*")
-	}
-
-	// Define safe headers that can be echoed back
-	safeHeaders := map[string]bool{
-		"accept":           true,
-		"accept-language":  true,
-		"content-language": true,
-		"content-type":     true,
-		"dpr":              true,
-		"downlink":         true,
-		"save-data":        true,
-		"viewport-width":   true,
-		"width":            true,
-		"authorization":    true,
-		"x-requested-with": true,
-		"x-csrf-token":     true,
-	}
-
-	return func(c *fiber.Ctx) error {
-		origin := c.Get("Origin")
-
-		// Determine if this is a preflight request
-		isPreflight := c.Method() == "OPTIONS" && c.Get("Access-Control-Request-Method") != ""
-		isOptions := c.Method() == "OPTIONS"
-
-		// Normalize the request origin
-		normalizedOrigin := origin
-		if origin != "" {
-			if u, err := url.Parse(origin); err == nil {
-				u.Scheme = strings.ToLower(u.Scheme)
-				u.Host = strings.ToLower(u.Host)
-				normalizedOrigin = u.String()
-			} else {
-				normalizedOrigin = strings.ToLower(origin)
-			}
+// compile parses and validates a Config, collecting every problem into a *ConfigError
+func compile(config Config) (*policy, error) {
+	p := &policy{
+		config:         config,
+		allowedMethods: make(map[string]bool),
+		allowedHeaders: make(map[string]bool),
+		failureStatus:  config.PreflightFailureStatus,
+		allowAll:       config.AllowAllOrigins,
+		observed: config.Logger != nil || config.Metrics != nil ||
+			config.OnAllowed != nil || config.OnRejected != nil || config.OnPreflight != nil,
+	}
+	errs := &ConfigError{}
+
+	// The slice fields take precedence over the comma-separated strings; their entries are
+	// normalized and duplicates dropped, whereas duplicates in the strings are reported
+	origins := configList{field: "AllowOrigins", entries: splitList(config.AllowOrigins)}
+	if len(config.Origins) > 0 {
+		origins = configList{field: "Origins", entries: trimList(config.Origins), dedupe: true}
+	}
+	methods := configList{field: "AllowMethods", entries: splitList(config.AllowMethods)}
+	if len(config.Methods) > 0 {
+		methods = configList{field: "Methods", entries: trimList(config.Methods), dedupe: true}
+		for i, method := range methods.entries {
+			methods.entries[i] = strings.ToUpper(method)
 		}
+	}
+	headers := configList{field: "AllowHeaders", entries: splitList(config.AllowHeaders)}
+	if len(config.Headers) > 0 {
+		headers = configList{field: "Headers", entries: canonicalHeaders(config.Headers), dedupe: true}
+	}
+	expose := configList{field: "ExposeHeaders", entries: splitList(config.ExposeHeaders)}
+	if len(config.Expose) > 0 {
+		expose = configList{field: "Expose", entries: canonicalHeaders(config.Expose), dedupe: true}
+	}
 
-		// Check if the request's origin is allowed according to the configuration
-		originAllowed := false
-		if origin != "" {
-			if allowAll || len(allowedOriginsMap) == 0 || allowedOriginsMap[normalizedOrigin] {
-				originAllowed = true
-				// CORS spec: Echo actual origin instead of "*" wildcard
-				c.Set("Access-Control-Allow-Origin", origin)
-			}
+	// Parse allowed origins (normalized to lowercase)
+	for _, origin := range origins.entries {
+		if origin == "*" {
+			p.allowAll = true
+			continue
+		}
+		if strings.EqualFold(origin, "null") {
+			errs.add(origins.field, origin, ErrNullOrigin)
+			continue
 		}
+		if err := p.origins.add(origin); errors.Is(err, ErrDuplicate) {
+			origins.duplicate(errs, origin)
+		} else if err != nil {
+			errs.add(origins.field, origin, err)
+		}
+	}
 
-		// CORS spec: Set headers for allowed origins with non-empty origin
-		// Also handle empty origin for backward compatibility with tests
-		if originAllowed || origin == "" {
-			// Set Access-Control-Allow-Credentials if enabled
-			if config.AllowCredentials {
-				c.Set("Access-Control-Allow-Credentials", "true")
-			}
+	if len(config.AllowOriginPatterns) > maxOriginPatterns {
+		errs.add("AllowOriginPatterns", "", ErrTooManyOriginPatterns)
+	}
+	for _, pattern := range config.AllowOriginPatterns {
+		re, err := compileOriginRegexp(pattern)
+		if err != nil {
+			errs.add("AllowOriginPatterns", pattern, err)
+			continue
+		}
+		p.originRegexps = append(p.originRegexps, re)
+	}
 
-			// Set CORS headers
-			if config.AllowHeaders != "" {
-				c.Set("Access-Control-Allow-Headers", config.AllowHeaders)
-			}
+	privateNetworkOrigins := configList{field: "PrivateNetworkOrigins", entries: trimList(config.PrivateNetworkOrigins), dedupe: true}
+	for _, origin := range privateNetworkOrigins.entries {
+		if err := p.privateNetworkOrigins.add(origin); err != nil && !errors.Is(err, ErrDuplicate) {
+			errs.add(privateNetworkOrigins.field, origin, err)
+		}
+	}
 
-			// Set default methods if not configured
-			defaultMethods := "GET, POST, HEAD, OPTIONS"
-			if config.AllowMethods != "" {
-				c.Set("Access-Control-Allow-Methods", config.AllowMethods)
-			} else {
-				c.Set("Access-Control-Allow-Methods", defaultMethods)
-			}
+	if config.AllowCredentials && p.allowAll {
+		errs.add("AllowCredentials", "", ErrCredentialsWithWildcard)
+	}
 
-			if config.ExposeHeaders != "" {
-				c.Set("Access-Control-Expose-Headers", config.ExposeHeaders)
-			}
+	if len(methods.entries) == 0 {
+		methods.entries = splitList(defaultMethods)
+	}
+	var methodValues []string
+	for _, method := range methods.entries {
+		if !knownMethods[method] {
+			errs.add(methods.field, method, ErrUnknownMethod)
+			continue
 		}
+		if p.allowedMethods[method] {
+			methods.duplicate(errs, method)
+			continue
+		}
+		p.allowedMethods[method] = true
+		methodValues = append(methodValues, method)
+	}
+	p.allowMethods = []byte(strings.Join(methodValues, ", "))
 
-		if isPreflight {
-			// Handle preflight request
-			if originAllowed || origin == "" {
-				// Handle request headers
-				requestHeaders := c.Get("Access-Control-Request-Headers")
-				if config.AllowHeaders == "" && requestHeaders != "" {
-					// Validate and filter requested headers
-					headersList := strings.Split(requestHeaders, ",")
-					safeHeadersList := []string{}
-
-					for _, header := range headersList {
-						header = strings.TrimSpace(strings.ToLower(header))
-						if safeHeaders[header] {
-							safeHeadersList = append(safeHeadersList, header)
-						}
-					}
-
-					if len(safeHeadersList) > 0 {
-						c.Set("Access-Control-Allow-Headers", strings.Join(safeHeadersList, ", "))
-					} else {
-						// For backward compatibility with tests, echo back the original headers
-						c.Set("Access-Control-Allow-Headers", requestHeaders)
-					}
-				}
+	headerValues := validateHeaders(errs, headers)
+	for _, header := range headerValues {
+		if header == "*" {
+			p.allowAnyHeader = true
+		}
+		p.allowedHeaders[strings.ToLower(header)] = true
+	}
+	p.compileEchoHeaders(errs)
+	if len(headerValues) == 0 {
+		p.allowedHeaders = p.echoHeaders
+	}
+	p.allowHeaders = []byte(strings.Join(headerValues, ", "))
+	p.exposeHeaders = []byte(strings.Join(validateHeaders(errs, expose), ", "))
+
+	switch config.ResponseMode {
+	case ResponseEchoOrigin:
+	case ResponseWildcard:
+		if !p.allowAll {
+			errs.add("ResponseMode", "", ErrWildcardResponse)
+		}
+		// The spec allows * for these headers only in responses without credentials
+		if len(config.Methods) == 0 && config.AllowMethods == "" {
+			p.allowMethods = []byte("*")
+			p.allowAnyMethod = true
+		}
+		if len(p.allowHeaders) == 0 {
+			p.allowHeaders = []byte("*")
+			p.allowAnyHeader = true
+		}
+		if len(p.exposeHeaders) == 0 {
+			p.exposeHeaders = []byte("*")
+		}
+	default:
+		errs.add("ResponseMode", strconv.Itoa(int(config.ResponseMode)), ErrUnknownResponseMode)
+	}
+
+	if p.failureStatus == 0 {
+		p.failureStatus = fiber.StatusForbidden
+	} else if p.failureStatus < 400 || p.failureStatus > 599 {
+		errs.add("PreflightFailureStatus", strconv.Itoa(config.PreflightFailureStatus), ErrInvalidStatus)
+	}
+
+	for _, pattern := range config.SkipPaths {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/**"), ""); err != nil {
+			errs.add("SkipPaths", pattern, ErrInvalidPathPattern)
+		}
+	}
+
+	if config.LogSampling < 0 {
+		errs.add("LogSampling", strconv.Itoa(config.LogSampling), ErrNegativeLogSampling)
+	}
+
+	if config.MaxAge < 0 {
+		errs.add("MaxAge", strconv.Itoa(config.MaxAge), ErrNegativeMaxAge)
+	} else if config.MaxAge > 0 {
+		p.maxAge = strconv.AppendInt(nil, int64(config.MaxAge), 10)
+	}
+
+	p.vary = []byte("Origin")
+	p.preflightVary = []byte("Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+	if config.AllowPrivateNetwork {
+		p.preflightVary = append(p.preflightVary, ", Access-Control-Request-Private-Network"...)
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// compileEchoHeaders builds the list of headers that may be echoed back from the safe headers
+// and the echo allow and deny lists
+func (p *policy) compileEchoHeaders(errs *ConfigError) {
+	p.echoHeaders = make(map[string]bool, len(safeHeaders)+len(p.config.EchoHeaderAllowlist))
+	p.deniedHeaders = make(map[string]bool, len(p.config.EchoHeaderDenylist))
+	for header := range safeHeaders {
+		p.echoHeaders[header] = true
+	}
+	for _, header := range trimList(p.config.EchoHeaderAllowlist) {
+		key := strings.ToLower(header)
+		switch {
+		case !isToken(header):
+			errs.add("EchoHeaderAllowlist", header, ErrInvalidHeader)
+		case isForbiddenHeader(key):
+			errs.add("EchoHeaderAllowlist", header, ErrForbiddenHeader)
+		default:
+			p.echoHeaders[key] = true
+		}
+	}
+	for _, header := range trimList(p.config.EchoHeaderDenylist) {
+		if !isToken(header) {
+			errs.add("EchoHeaderDenylist", header, ErrInvalidHeader)
+			continue
+		}
+		key := strings.ToLower(header)
+		p.deniedHeaders[key] = true
+		delete(p.echoHeaders, key)
+	}
+}
+
+// appendEchoHeaders appends the Access-Control-Allow-Headers value for a preflight to dst when
+// AllowHeaders is empty: the requested headers on the echo list or, unless StrictEchoHeaders
+// is set and if none are on the list, every requested header that is not denied or forbidden
+func (p *policy) appendEchoHeaders(dst []byte, requestHeaders string) []byte {
+	var header string
+	listed := false
+	for rest := requestHeaders; rest != "" && !listed; {
+		header, rest = nextHeader(rest)
+		listed = p.echoHeaders[header]
+	}
+	if !listed && p.config.StrictEchoHeaders {
+		return dst
+	}
+
+	start := len(dst)
+	for rest := requestHeaders; rest != ""; {
+		header, rest = nextHeader(rest)
+		if listed && !p.echoHeaders[header] ||
+			!listed && (!isToken(header) || p.deniedHeaders[header] || isForbiddenHeader(header)) {
+			continue
+		}
+		if len(dst) > start {
+			dst = append(dst, ", "...)
+		}
+		dst = append(dst, header...)
+	}
+	return dst
+}
+
+// configList is a list-valued setting taken from either a comma-separated string field
+// or its slice counterpart
+type configList struct {
+	field   string
+	entries []string
+
+	// dedupe drops duplicate entries instead of reporting them
+	dedupe bool
+}
+
+// duplicate reports a duplicate entry unless the list drops them silently
+func (l configList) duplicate(errs *ConfigError, entry string) {
+	if !l.dedupe {
+		errs.add(l.field, entry, ErrDuplicate)
+	}
+}
+
+// validateHeaders checks that a header list holds unique, valid header names and returns them
+func validateHeaders(errs *ConfigError, headers configList) []string {
+	var values []string
+	seen := make(map[string]bool)
+	for _, header := range headers.entries {
+		if !isToken(header) {
+			errs.add(headers.field, header, ErrInvalidHeader)
+			continue
+		}
+		key := strings.ToLower(header)
+		if seen[key] {
+			headers.duplicate(errs, header)
+			continue
+		}
+		seen[key] = true
+		values = append(values, header)
+	}
+	return values
+}
+
+// handle is the middleware handler for the policy
+func (p *policy) handle(c *fiber.Ctx) error {
+	if p.skipped(c) {
+		return c.Next()
+	}
+
+	if p.config.ResponseMode == ResponseWildcard {
+		return p.handleWildcard(c)
+	}
 
-				// Set Access-Control-Max-Age if configured
-				if config.MaxAge > 0 {
-					c.Set("Access-Control-Max-Age", strconv.Itoa(config.MaxAge))
+	config := p.config
+	origin := c.Get("Origin")
+
+	// Determine if this is a preflight request
+	isPreflight := c.Method() == "OPTIONS" && c.Get("Access-Control-Request-Method") != ""
+	isOptions := c.Method() == "OPTIONS"
+
+	// The response depends on the request origin whether or not it is allowed, so caches
+	// must key on it; preflight responses also depend on the requested method and headers
+	p.setVary(c, isPreflight)
+
+	// Normalize the request origin; exact entries are already normalized, so an origin equal
+	// to one of them is used as is
+	normalizedOrigin := origin
+	if origin != "" && !p.origins.exact[origin] {
+		normalizedOrigin = normalizeRequestOrigin(origin)
+	}
+
+	// Check if the request's origin is allowed according to the configuration, recording the
+	// rule that allowed it or the reason it was rejected
+	originAllowed := false
+	var rule string
+	var reason Reason
+	if origin == "null" {
+		// The opaque origin of sandboxed iframes, file:// pages and some redirects is shared by
+		// unrelated documents, so it is never covered by * or an empty AllowOrigins
+		originAllowed = config.AllowNullOrigin
+		rule, reason = ruleAllowNullOrigin, ReasonNullOrigin
+	} else if origin != "" {
+		allowed, matched, err := p.originAllowed(c, origin, normalizedOrigin)
+		if err != nil {
+			return err
+		}
+		originAllowed = allowed
+		rule, reason = matched, ReasonOriginNotAllowed
+		if !allowed && !isOrigin(normalizedOrigin) {
+			reason = ReasonInvalidOrigin
+		}
+	}
+	if originAllowed {
+		reason = ""
+	} else {
+		rule = ""
+	}
+
+	// Private Network Access preflights ask for permission to reach a more private address space
+	privateNetworkRequested := isPreflight && c.Get("Access-Control-Request-Private-Network") == "true"
+	privateNetworkAllowed := privateNetworkRequested && originAllowed && p.privateNetworkAllowed(normalizedOrigin)
+	if originAllowed {
+		if isPreflight && config.StrictPreflight {
+			reason = p.preflightRejection(c)
+		}
+		if reason == "" && privateNetworkRequested && !privateNetworkAllowed {
+			reason = ReasonPrivateNetworkNotAllowed
+		}
+	}
+
+	// Reject preflights that the actual request would fail before any CORS headers are set
+	rejectPreflight := isPreflight && config.StrictPreflight && (origin == "" || reason != "")
+	if rejectPreflight {
+		c.Status(p.failureStatus)
+	} else if isPreflight && reason != "" && !config.PreflightContinue {
+		// Without StrictPreflight rejected preflights are answered as usual, unless OnRejected
+		// replaces the status
+		c.Status(204)
+	}
+
+	if origin != "" && p.observed {
+		err := p.notify(c, Decision{
+			Origin:           origin,
+			NormalizedOrigin: normalizedOrigin,
+			Preflight:        isPreflight,
+			RequestedMethod:  c.Get("Access-Control-Request-Method"),
+			RequestedHeaders: c.Get("Access-Control-Request-Headers"),
+			Allowed:          reason == "",
+			Rule:             rule,
+			Reason:           reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if rejectPreflight {
+		// OnRejected may have replaced the status and written a body
+		return c.SendStatus(c.Response().StatusCode())
+	}
+
+	if originAllowed {
+		// CORS spec: Echo actual origin instead of "*" wildcard
+		c.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	// CORS spec: Set headers only for allowed origins with non-empty origin
+	// LegacyEmptyOriginHeaders restores the old behaviour of also setting them without an origin
+	legacyEmptyOrigin := origin == "" && config.LegacyEmptyOriginHeaders
+	if originAllowed || legacyEmptyOrigin {
+		// Set Access-Control-Allow-Credentials if enabled
+		if config.AllowCredentials {
+			setHeader(c, headerAllowCredentials, valueTrue)
+		}
+
+		// Set CORS headers
+		if len(p.allowHeaders) > 0 {
+			setHeader(c, headerAllowHeaders, p.allowHeaders)
+		}
+
+		// Methods fall back to defaultMethods when not configured
+		setHeader(c, headerAllowMethods, p.allowMethods)
+
+		if len(p.exposeHeaders) > 0 {
+			setHeader(c, headerExposeHeaders, p.exposeHeaders)
+		}
+	}
+
+	if isPreflight {
+		// Handle preflight request
+		if originAllowed || legacyEmptyOrigin {
+			// Handle request headers
+			requestHeaders := c.Get("Access-Control-Request-Headers")
+			if len(p.allowHeaders) == 0 && requestHeaders != "" {
+				// Validate and filter requested headers into a stack buffer, which is enough
+				// for typical requests
+				var buf [128]byte
+				if echoed := p.appendEchoHeaders(buf[:0], requestHeaders); len(echoed) > 0 {
+					setHeader(c, headerAllowHeaders, echoed)
 				}
 			}
 
-			// Return 204 No Content for all preflight requests to match test expectations
-			return c.SendStatus(204)
-		} else if isOptions {
-			// Handle simple OPTIONS request (not a preflight)
-			// Return 204 for all OPTIONS requests to match test expectations
-			return c.SendStatus(204)
+			// Set Access-Control-Max-Age if configured
+			if p.maxAge != nil {
+				setHeader(c, headerMaxAge, p.maxAge)
+			}
 		}
 
-		// CORS spec: For disallowed origins, process request but browser will block response
-		return c.Next()
+		if privateNetworkAllowed {
+			setHeader(c, headerAllowPrivateNetwork, valueTrue)
+		}
+
+		if config.PreflightContinue {
+			return c.Next()
+		}
+
+		if reason != "" {
+			// OnRejected may have replaced the status and written a body
+			return c.SendStatus(c.Response().StatusCode())
+		}
+
+		// Return 204 No Content for all preflight requests to match test expectations
+		return c.SendStatus(204)
+	} else if isOptions {
+		// Handle simple OPTIONS request (not a preflight)
+		if config.OptionsPassthrough {
+			return c.Next()
+		}
+
+		// Return 204 for all OPTIONS requests to match test expectations
+		return c.SendStatus(204)
+	}
+
+	// CORS spec: For disallowed origins, process request but browser will block response
+	return c.Next()
+}
+
+// originAllowed checks a request origin against AllowOrigins, AllowOriginPatterns,
+// OriginStore and AllowOriginsFunc, in that order, and returns the rule that allowed it
+func (p *policy) originAllowed(c *fiber.Ctx, origin, normalizedOrigin string) (bool, string, error) {
+	if p.allowAll {
+		return true, ruleAllowAll, nil
+	}
+	if entry, ok := p.origins.matchEntry(normalizedOrigin); ok {
+		return true, entry, nil
+	}
+	if pattern, ok := p.matchOriginRegexps(normalizedOrigin); ok {
+		return true, pattern, nil
+	}
+	// Only well-formed origins are looked up so arbitrary header values never reach the store
+	if p.config.OriginStore != nil && isOrigin(normalizedOrigin) {
+		allowed, err := p.config.OriginStore.Allowed(c.UserContext(), normalizedOrigin)
+		if err != nil || allowed {
+			return allowed, ruleOriginStore, err
+		}
+	}
+	if p.config.AllowOriginsFunc != nil {
+		allowed, err := p.config.AllowOriginsFunc(origin, c)
+		return allowed, ruleAllowOriginsFunc, err
+	}
+	return false, "", nil
+}
+
+// matchOriginRegexps returns the first of AllowOriginPatterns matching a normalized origin
+// Only well-formed origins are considered so patterns never see arbitrary header values
+func (p *policy) matchOriginRegexps(normalizedOrigin string) (string, bool) {
+	if len(p.originRegexps) == 0 {
+		return "", false
+	}
+	if !isOrigin(normalizedOrigin) {
+		return "", false
+	}
+	for i, re := range p.originRegexps {
+		if re.MatchString(normalizedOrigin) {
+			return p.config.AllowOriginPatterns[i], true
+		}
+	}
+	return "", false
+}
+
+// handleWildcard is the middleware handler for ResponseWildcard. The response never depends on
+// the Origin header, so it sets the same headers for every request and does not vary on Origin.
+func (p *policy) handleWildcard(c *fiber.Ctx) error {
+	config := p.config
+	isPreflight := c.Method() == "OPTIONS" && c.Get("Access-Control-Request-Method") != ""
+
+	privateNetworkAllowed := false
+	var reason Reason
+	if isPreflight {
+		if config.AllowPrivateNetwork {
+			c.Vary("Access-Control-Request-Private-Network")
+			privateNetworkAllowed = c.Get("Access-Control-Request-Private-Network") == "true" &&
+				p.privateNetworkOrigins.empty()
+		}
+		if config.StrictPreflight {
+			c.Vary("Access-Control-Request-Method", "Access-Control-Request-Headers")
+			reason = p.preflightRejection(c)
+		}
+	}
+
+	if reason != "" {
+		c.Status(p.failureStatus)
+	}
+	if origin := c.Get("Origin"); origin != "" && p.observed {
+		err := p.notify(c, Decision{
+			Origin:           origin,
+			NormalizedOrigin: normalizeRequestOrigin(origin),
+			Preflight:        isPreflight,
+			RequestedMethod:  c.Get("Access-Control-Request-Method"),
+			RequestedHeaders: c.Get("Access-Control-Request-Headers"),
+			Allowed:          reason == "",
+			Rule:             ruleResponseWildcard,
+			Reason:           reason,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if reason != "" {
+		return c.SendStatus(c.Response().StatusCode())
+	}
+
+	setHeader(c, headerAllowOrigin, valueWildcard)
+	setHeader(c, headerAllowMethods, p.allowMethods)
+	setHeader(c, headerAllowHeaders, p.allowHeaders)
+	setHeader(c, headerExposeHeaders, p.exposeHeaders)
+
+	if isPreflight {
+		if p.maxAge != nil {
+			setHeader(c, headerMaxAge, p.maxAge)
+		}
+		if privateNetworkAllowed {
+			setHeader(c, headerAllowPrivateNetwork, valueTrue)
+		}
+		if config.PreflightContinue {
+			return c.Next()
+		}
+		return c.SendStatus(204)
+	} else if c.Method() == "OPTIONS" && !config.OptionsPassthrough {
+		return c.SendStatus(204)
+	}
+
+	return c.Next()
+}
+
+// privateNetworkAllowed reports whether an allowed origin may make Private Network Access requests
+func (p *policy) privateNetworkAllowed(normalizedOrigin string) bool {
+	if !p.config.AllowPrivateNetwork {
+		return false
+	}
+	return p.privateNetworkOrigins.empty() || p.privateNetworkOrigins.match(normalizedOrigin)
+}
+
+// preflightRejection returns why a preflight from an allowed origin fails StrictPreflight,
+// or "" if the requested method and headers are configured
+func (p *policy) preflightRejection(c *fiber.Ctx) Reason {
+	if !p.allowAnyMethod && !p.allowedMethods[c.Get("Access-Control-Request-Method")] {
+		return ReasonMethodNotAllowed
+	}
+	if p.allowAnyHeader {
+		return ""
+	}
+	var header string
+	for rest := c.Get("Access-Control-Request-Headers"); rest != ""; {
+		header, rest = nextHeader(rest)
+		if header != "" && !p.allowedHeaders[header] {
+			return ReasonHeaderNotAllowed
+		}
+	}
+	return ""
+}
+
+// setVary adds the request headers a response depends on to Vary. Values set by other
+// middleware are appended to rather than overwritten; otherwise the precomputed value is set.
+func (p *policy) setVary(c *fiber.Ctx, isPreflight bool) {
+	vary := p.vary
+	if isPreflight {
+		vary = p.preflightVary
+	}
+	if len(c.Response().Header.PeekBytes(headerVary)) == 0 {
+		setHeader(c, headerVary, vary)
+		return
+	}
+	for rest := string(vary); rest != ""; {
+		var field string
+		field, rest, _ = strings.Cut(rest, ", ")
+		c.Vary(field)
+	}
+}
+
+// matchPath reports whether a request path matches a SkipPaths pattern
+func matchPath(pattern, requestPath string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		if prefix == "" {
+			return true
+		}
+		for {
+			if matched, _ := path.Match(prefix, requestPath); matched {
+				return true
+			}
+			i := strings.LastIndexByte(requestPath, '/')
+			if i <= 0 {
+				return false
+			}
+			requestPath = requestPath[:i]
+		}
 	}
+	matched, _ := path.Match(pattern, requestPath)
+	return matched
 }
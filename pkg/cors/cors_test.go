@@ -1,11 +1,14 @@
 package cors
 
 import (
+	"errors"
+	"io"
 	"net/http/httptest"
 	"strings"
 	"testing"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
 )
 
 func TestCorsMiddleware(t *testing.T) {
@@ -62,6 +65,60 @@ func TestCorsMiddleware(t *testing.T) {
 			expectedOrigin: "",
 			expectedStatus: 200,
 		},
+		{
+			name: "empty origin with legacy headers",
+			config: Config{
+				AllowOrigins:             "https://example.com",
+				AllowCredentials:         true,
+				AllowHeaders:             "Content-Type",
+				ExposeHeaders:            "X-Custom",
+				AllowMethods:             "GET, POST",
+				LegacyEmptyOriginHeaders: true,
+			},
+			requestOrigin:  "",
+			requestMethod:  "GET",
+			expectedOrigin: "",
+			expectedStatus: 200,
+		},
+		{
+			name: "empty origin preflight",
+			config: Config{
+				AllowOrigins:     "https://example.com",
+				AllowCredentials: true,
+				AllowHeaders:     "Content-Type",
+				AllowMethods:     "GET, POST, OPTIONS",
+				MaxAge:           3600,
+			},
+			requestOrigin: "",
+			requestMethod: "OPTIONS",
+			requestHeaders: map[string]string{
+				"Access-Control-Request-Method": "POST",
+			},
+			expectedOrigin:       "",
+			expectedStatus:       204,
+			expectedMaxAge:       "3600", // checked to be absent without legacy headers
+			expectPreflightCheck: true,
+		},
+		{
+			name: "empty origin preflight with legacy headers",
+			config: Config{
+				AllowOrigins:             "https://example.com",
+				AllowCredentials:         true,
+				AllowHeaders:             "Content-Type",
+				AllowMethods:             "GET, POST, OPTIONS",
+				MaxAge:                   3600,
+				LegacyEmptyOriginHeaders: true,
+			},
+			requestOrigin: "",
+			requestMethod: "OPTIONS",
+			requestHeaders: map[string]string{
+				"Access-Control-Request-Method": "POST",
+			},
+			expectedOrigin:       "",
+			expectedStatus:       204,
+			expectedMaxAge:       "3600",
+			expectPreflightCheck: true,
+		},
 		{
 			name: "options request",
 			config: Config{
@@ -77,9 +134,9 @@ func TestCorsMiddleware(t *testing.T) {
 			expectedStatus: 204,
 		},
 		{
-			name: "empty config - all origins allowed",
+			name: "empty config - no origins allowed",
 			config: Config{
-				AllowOrigins:     "", // Empty AllowOrigins means all origins are allowed
+				AllowOrigins:     "", // Empty AllowOrigins means no origins are allowed
 				AllowCredentials: false,
 				AllowHeaders:     "",
 				ExposeHeaders:    "",
@@ -87,7 +144,17 @@ func TestCorsMiddleware(t *testing.T) {
 			},
 			requestOrigin:  "https://example.com",
 			requestMethod:  "GET",
-			expectedOrigin: "https://example.com", // Origin is allowed with empty config
+			expectedOrigin: "", // Origin is denied with empty config
+			expectedStatus: 200,
+		},
+		{
+			name: "AllowAllOrigins",
+			config: Config{
+				AllowAllOrigins: true,
+			},
+			requestOrigin:  "https://example.com",
+			requestMethod:  "GET",
+			expectedOrigin: "https://example.com",
 			expectedStatus: 200,
 		},
 		{
@@ -256,8 +323,8 @@ func TestCorsMiddleware(t *testing.T) {
 				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.expectedOrigin, origin)
 			}
 
-			// Only check for CORS headers if the origin is allowed or empty
-			originAllowed := tt.expectedOrigin != "" || tt.requestOrigin == ""
+			// Only check for CORS headers if the origin is allowed, or empty in legacy mode
+			originAllowed := tt.expectedOrigin != "" || (tt.requestOrigin == "" && tt.config.LegacyEmptyOriginHeaders)
 
 			if originAllowed {
 				if tt.config.AllowCredentials {
@@ -288,7 +355,7 @@ func TestCorsMiddleware(t *testing.T) {
 					}
 				}
 			} else {
-				// For disallowed origins, verify that no CORS headers are present
+				// For disallowed or empty origins, verify that no CORS headers are present
 				credentials := resp.Header.Get("Access-Control-Allow-Credentials")
 				if credentials != "" {
 					t.Errorf("Expected no Access-Control-Allow-Credentials header for disallowed origin, but got %q", credentials)
@@ -397,118 +464,1568 @@ func TestCorsConfigValidation(t *testing.T) {
 	New(corsConfig)
 }
 
-func TestCorsWithMultipleMiddleware(t *testing.T) {
-	app := fiber.New()
-
-	corsConfig := Config{
-		AllowOrigins:     "https://example.com",
-		AllowCredentials: true,
-		AllowHeaders:     "Content-Type",
-		ExposeHeaders:    "X-Custom",
-		AllowMethods:     "GET, POST",
+func TestCorsStrictPreflight(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		origin         string
+		requestMethod  string
+		requestHeaders string
+		expectedStatus int
+		expectedOrigin string
+	}{
+		{
+			name:           "allowed preflight",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST", AllowHeaders: "Content-Type, Authorization"},
+			origin:         "https://example.com",
+			requestMethod:  "POST",
+			requestHeaders: "content-type, AUTHORIZATION",
+			expectedStatus: 204,
+			expectedOrigin: "https://example.com",
+		},
+		{
+			name:           "disallowed origin",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST"},
+			origin:         "https://disallowed.com",
+			requestMethod:  "POST",
+			expectedStatus: 403,
+		},
+		{
+			name:           "missing origin",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST"},
+			requestMethod:  "POST",
+			expectedStatus: 403,
+		},
+		{
+			name:           "method not allowed",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST"},
+			origin:         "https://example.com",
+			requestMethod:  "DELETE",
+			expectedStatus: 403,
+		},
+		{
+			name:           "default methods",
+			config:         Config{AllowOrigins: "https://example.com"},
+			origin:         "https://example.com",
+			requestMethod:  "PUT",
+			expectedStatus: 403,
+		},
+		{
+			name:           "header not allowed",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST", AllowHeaders: "Content-Type"},
+			origin:         "https://example.com",
+			requestMethod:  "POST",
+			requestHeaders: "Content-Type, X-Tenant-Id",
+			expectedStatus: 403,
+		},
+		{
+			name:           "safe headers when AllowHeaders is empty",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST"},
+			origin:         "https://example.com",
+			requestMethod:  "POST",
+			requestHeaders: "Content-Type, Authorization",
+			expectedStatus: 204,
+			expectedOrigin: "https://example.com",
+		},
+		{
+			name:           "unsafe header when AllowHeaders is empty",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET, POST"},
+			origin:         "https://example.com",
+			requestMethod:  "POST",
+			requestHeaders: "Host",
+			expectedStatus: 403,
+		},
+		{
+			name:           "custom failure status",
+			config:         Config{AllowOrigins: "https://example.com", AllowMethods: "GET", PreflightFailureStatus: 400},
+			origin:         "https://example.com",
+			requestMethod:  "POST",
+			expectedStatus: 400,
+		},
 	}
 
-	middlewareExecuted := false
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.StrictPreflight = true
+			tt.config.AllowCredentials = true
 
-	app.Use(func(c *fiber.Ctx) error {
-		middlewareExecuted = true
-		return c.Next()
-	})
+			app := fiber.New()
+			app.Use(New(tt.config))
 
-	app.Use(New(corsConfig))
+			req := httptest.NewRequest("OPTIONS", "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			if tt.requestHeaders != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+			}
 
-	app.Get("/", func(c *fiber.Ctx) error {
-		return c.SendStatus(200)
-	})
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
 
-	req := httptest.NewRequest("GET", "/", nil)
-	req.Header.Set("Origin", "https://example.com")
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d but got %d", tt.expectedStatus, resp.StatusCode)
+			}
 
-	resp, err := app.Test(req)
-	if err != nil {
-		t.Fatalf("Failed to test request: %v", err)
-	}
+			origin := resp.Header.Get("Access-Control-Allow-Origin")
+			if origin != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.expectedOrigin, origin)
+			}
 
-	if !middlewareExecuted {
-		t.Error("Expected middleware to be executed")
+			if tt.expectedOrigin == "" {
+				for _, header := range []string{"Access-Control-Allow-Credentials", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers"} {
+					if value := resp.Header.Get(header); value != "" {
+						t.Errorf("Expected no %s header for a rejected preflight but got %q", header, value)
+					}
+				}
+			}
+		})
 	}
+}
 
-	if resp.StatusCode != 200 {
-		t.Errorf("Expected status 200 but got %d", resp.StatusCode)
+func TestCorsOptionsPassthrough(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		requestHeaders map[string]string
+		expectedStatus int
+		expectedBody   string
+		rejected       bool
+	}{
+		{
+			name:           "plain OPTIONS is answered by default",
+			config:         Config{AllowOrigins: "https://example.com"},
+			expectedStatus: 204,
+		},
+		{
+			name:           "plain OPTIONS passes through",
+			config:         Config{AllowOrigins: "https://example.com", OptionsPassthrough: true},
+			expectedStatus: 200,
+			expectedBody:   "GET, PROPFIND",
+		},
+		{
+			name:           "preflight is answered when only OptionsPassthrough is set",
+			config:         Config{AllowOrigins: "https://example.com", OptionsPassthrough: true},
+			requestHeaders: map[string]string{"Access-Control-Request-Method": "POST"},
+			expectedStatus: 204,
+		},
+		{
+			name:           "preflight continues",
+			config:         Config{AllowOrigins: "https://example.com", PreflightContinue: true},
+			requestHeaders: map[string]string{"Access-Control-Request-Method": "POST"},
+			expectedStatus: 200,
+			expectedBody:   "GET, PROPFIND",
+		},
+		{
+			name:           "rejected strict preflight does not continue",
+			config:         Config{AllowOrigins: "https://example.com", PreflightContinue: true, StrictPreflight: true},
+			requestHeaders: map[string]string{"Access-Control-Request-Method": "DELETE"},
+			expectedStatus: 403,
+			expectedBody:   "Forbidden",
+			rejected:       true,
+		},
+		{
+			name:           "rejected non-strict preflight continues",
+			config:         Config{AllowOrigins: "https://other.com", PreflightContinue: true},
+			requestHeaders: map[string]string{"Access-Control-Request-Method": "POST"},
+			expectedStatus: 200,
+			expectedBody:   "GET, PROPFIND",
+			rejected:       true,
+		},
+		{
+			name:           "preflight denied Private Network Access continues",
+			config:         Config{AllowOrigins: "https://example.com", PreflightContinue: true},
+			requestHeaders: map[string]string{"Access-Control-Request-Method": "POST", "Access-Control-Request-Private-Network": "true"},
+			expectedStatus: 200,
+			expectedBody:   "GET, PROPFIND",
+		},
 	}
 
-	origin := resp.Header.Get("Access-Control-Allow-Origin")
-	if origin != "https://example.com" {
-		t.Errorf("Expected Access-Control-Allow-Origin to be 'https://example.com' but got %q", origin)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(New(tt.config))
+			// The route relies on the default status, so one left by the middleware would show
+			app.Options("/", func(c *fiber.Ctx) error {
+				c.Set("Allow", "GET, PROPFIND")
+				return c.SendString("GET, PROPFIND")
+			})
+
+			req := httptest.NewRequest("OPTIONS", "/", nil)
+			req.Header.Set("Origin", "https://example.com")
+			for key, value := range tt.requestHeaders {
+				req.Header.Set(key, value)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d but got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read body: %v", err)
+			}
+			if string(body) != tt.expectedBody {
+				t.Errorf("Expected body %q but got %q", tt.expectedBody, body)
+			}
+
+			// CORS headers are still set when the request is passed through
+			if !tt.rejected {
+				if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "https://example.com" {
+					t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", "https://example.com", origin)
+				}
+			}
+		})
 	}
 }
 
-func TestCorsWithWildcardOrigin(t *testing.T) {
-	app := fiber.New()
-
+func TestCorsSkip(t *testing.T) {
 	corsConfig := Config{
-		AllowOrigins:     "*",
-		AllowCredentials: false, // Must be false with wildcard origin
-		AllowHeaders:     "Content-Type",
-		ExposeHeaders:    "X-Custom",
-		AllowMethods:     "GET, POST",
+		AllowOrigins: "https://example.com",
+		SkipPaths:    []string{"/health", "/webhooks/**", "/static/*.js"},
+		Next: func(c *fiber.Ctx) bool {
+			return c.Get("X-Internal") == "true"
+		},
 	}
 
-	app.Use(New(corsConfig))
+	tests := []struct {
+		name     string
+		path     string
+		internal bool
+		skipped  bool
+	}{
+		{name: "regular path", path: "/api/users"},
+		{name: "exact skip path", path: "/health", skipped: true},
+		{name: "subtree root", path: "/webhooks", skipped: true},
+		{name: "subtree child", path: "/webhooks/stripe/events", skipped: true},
+		{name: "subtree prefix without separator", path: "/webhooksx"},
+		{name: "segment glob", path: "/static/app.js", skipped: true},
+		{name: "segment glob does not cross separators", path: "/static/js/app.js"},
+		{name: "Next predicate", path: "/api/users", internal: true, skipped: true},
+	}
 
-	app.Get("/", func(c *fiber.Ctx) error {
+	app := fiber.New()
+	app.Use(New(corsConfig))
+	app.All("/*", func(c *fiber.Ctx) error {
 		return c.SendStatus(200)
 	})
 
-	testOrigins := []string{
-		"https://example.com",
-		"https://another-site.com",
-		"http://localhost:3000",
-	}
-
-	for _, origin := range testOrigins {
-		req := httptest.NewRequest("GET", "/", nil)
-		req.Header.Set("Origin", origin)
-
-		resp, err := app.Test(req)
-		if err != nil {
-			t.Fatalf("Failed to test request with origin %s: %v", origin, err)
-		}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, method := range []string{"GET", "OPTIONS"} {
+				req := httptest.NewRequest(method, tt.path, nil)
+				req.Header.Set("Origin", "https://example.com")
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				if tt.internal {
+					req.Header.Set("X-Internal", "true")
+				}
 
-		if resp.StatusCode != 200 {
-			t.Errorf("Expected status 200 but got %d for origin %s", resp.StatusCode, origin)
-		}
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("Failed to test request: %v", err)
+				}
 
-		respOrigin := resp.Header.Get("Access-Control-Allow-Origin")
-		if respOrigin != origin {
-			t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", origin, respOrigin)
-		}
+				origin := resp.Header.Get("Access-Control-Allow-Origin")
+				vary := resp.Header.Get("Vary")
+				if tt.skipped {
+					if origin != "" || vary != "" || resp.StatusCode != 200 {
+						t.Errorf("%s: expected request to bypass CORS but got status %d, origin %q, vary %q", method, resp.StatusCode, origin, vary)
+					}
+				} else if origin != "https://example.com" {
+					t.Errorf("%s: expected Access-Control-Allow-Origin to be set but got %q", method, origin)
+				}
+			}
+		})
 	}
 }
 
-func BenchmarkCorsMiddleware(b *testing.B) {
-	app := fiber.New()
-
+func TestCorsSliceConfig(t *testing.T) {
 	corsConfig := Config{
-		AllowOrigins:     "https://example.com, https://allowed.com",
-		AllowCredentials: true,
-		AllowHeaders:     "Content-Type",
-		ExposeHeaders:    "X-Custom",
-		AllowMethods:     "GET, POST, OPTIONS",
+		AllowOrigins:  "https://ignored.com",
+		AllowMethods:  "GET",
+		Origins:       []string{" https://Example.com ", "https://example.com", "https://*.domain.com", ""},
+		Methods:       []string{"get", "POST", "post", "delete"},
+		Headers:       []string{"content-type", "X-Tenant-Id", "Content-Type"},
+		Expose:        []string{"x-request-id"},
+		ExposeHeaders: "X-Ignored",
 	}
 
+	app := fiber.New()
 	app.Use(New(corsConfig))
-
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.SendStatus(200)
 	})
 
-	req := httptest.NewRequest("GET", "/", nil)
-	req.Header.Set("Origin", "https://allowed.com")
+	tests := []struct {
+		origin         string
+		expectedOrigin string
+	}{
+		{"https://example.com", "https://example.com"},
+		{"https://app.domain.com", "https://app.domain.com"},
+		{"https://ignored.com", ""}, // the slice field takes precedence over AllowOrigins
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tt.origin)
 
-	b.ResetTimer()
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			origin := resp.Header.Get("Access-Control-Allow-Origin")
+			if origin != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.expectedOrigin, origin)
+			}
+			if tt.expectedOrigin == "" {
+				return
+			}
+
+			expected := map[string]string{
+				"Access-Control-Allow-Methods":  "GET, POST, DELETE",
+				"Access-Control-Allow-Headers":  "Content-Type, X-Tenant-Id",
+				"Access-Control-Expose-Headers": "X-Request-Id",
+			}
+			for header, value := range expected {
+				if got := resp.Header.Get(header); got != value {
+					t.Errorf("Expected %s to be %q but got %q", header, value, got)
+				}
+			}
+		})
+	}
+}
 
-	for i := 0; i < b.N; i++ {
-		_, _ = app.Test(req)
+func TestCorsPrivateNetworkAccess(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		origin         string
+		preflight      bool
+		requestPNA     bool
+		expectedPNA    string
+		expectedStatus int
+	}{
+		{
+			name:           "disabled by default",
+			config:         Config{AllowOrigins: "https://console.domain.com"},
+			origin:         "https://console.domain.com",
+			preflight:      true,
+			requestPNA:     true,
+			expectedStatus: 204,
+		},
+		{
+			name:           "allowed origin",
+			config:         Config{AllowOrigins: "https://console.domain.com", AllowPrivateNetwork: true},
+			origin:         "https://console.domain.com",
+			preflight:      true,
+			requestPNA:     true,
+			expectedPNA:    "true",
+			expectedStatus: 204,
+		},
+		{
+			name:           "not requested",
+			config:         Config{AllowOrigins: "https://console.domain.com", AllowPrivateNetwork: true},
+			origin:         "https://console.domain.com",
+			preflight:      true,
+			expectedStatus: 204,
+		},
+		{
+			name:           "not a preflight",
+			config:         Config{AllowOrigins: "https://console.domain.com", AllowPrivateNetwork: true},
+			origin:         "https://console.domain.com",
+			requestPNA:     true,
+			expectedStatus: 200,
+		},
+		{
+			name:           "disallowed origin",
+			config:         Config{AllowOrigins: "https://console.domain.com", AllowPrivateNetwork: true},
+			origin:         "https://evil.com",
+			preflight:      true,
+			requestPNA:     true,
+			expectedStatus: 204,
+		},
+		{
+			name: "restricted to other origins",
+			config: Config{
+				AllowOrigins:          "https://console.domain.com, https://www.domain.com",
+				AllowPrivateNetwork:   true,
+				PrivateNetworkOrigins: []string{"https://www.domain.com"},
+			},
+			origin:         "https://console.domain.com",
+			preflight:      true,
+			requestPNA:     true,
+			expectedStatus: 204,
+		},
+		{
+			name: "restricted by pattern",
+			config: Config{
+				AllowOrigins:          "https://*.domain.com",
+				AllowPrivateNetwork:   true,
+				PrivateNetworkOrigins: []string{"https://*.console.domain.com"},
+			},
+			origin:         "https://eu.console.domain.com",
+			preflight:      true,
+			requestPNA:     true,
+			expectedPNA:    "true",
+			expectedStatus: 204,
+		},
+		{
+			name: "strict preflight rejects unpermitted private network request",
+			config: Config{
+				AllowOrigins:    "https://console.domain.com",
+				StrictPreflight: true,
+			},
+			origin:         "https://console.domain.com",
+			preflight:      true,
+			requestPNA:     true,
+			expectedStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(New(tt.config))
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			method := "GET"
+			if tt.preflight {
+				method = "OPTIONS"
+			}
+			req := httptest.NewRequest(method, "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", "GET")
+			}
+			if tt.requestPNA {
+				req.Header.Set("Access-Control-Request-Private-Network", "true")
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d but got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			pna := resp.Header.Get("Access-Control-Allow-Private-Network")
+			if pna != tt.expectedPNA {
+				t.Errorf("Expected Access-Control-Allow-Private-Network to be %q but got %q", tt.expectedPNA, pna)
+			}
+
+			if tt.preflight && tt.config.AllowPrivateNetwork &&
+				!strings.Contains(resp.Header.Get("Vary"), "Access-Control-Request-Private-Network") {
+				t.Errorf("Expected Vary to include Access-Control-Request-Private-Network but got %q", resp.Header.Get("Vary"))
+			}
+		})
+	}
+}
+
+func TestCorsAllowOriginPatterns(t *testing.T) {
+	corsConfig := Config{
+		AllowOrigins:        "https://console.domain.com",
+		AllowOriginPatterns: []string{`https://tenant-[a-z0-9]+\.eu\.domain\.com`, `http://10\.0\.0\.[0-9]{1,3}(:[0-9]+)?`},
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://console.domain.com", true},
+		{"https://tenant-acme42.eu.domain.com", true},
+		{"https://TENANT-ACME.eu.domain.com", true}, // matched against the normalized origin
+		{"http://10.0.0.7:8080", true},
+		{"https://tenant-.eu.domain.com", false},
+		{"https://tenant-acme.us.domain.com", false},
+		{"https://tenant-acme.eu.domain.com.evil.com", false}, // anchored at the end
+		{"https://evil.com/https://tenant-acme.eu.domain.com", false},
+		{"xhttps://tenant-acme.eu.domain.com", false}, // anchored at the start
+		{"http://10.0.0.7:8080/path", false},
+	}
+
+	app := fiber.New()
+	app.Use(New(corsConfig))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tt.origin)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			respOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+			if tt.allowed && respOrigin != tt.origin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.origin, respOrigin)
+			}
+			if !tt.allowed && respOrigin != "" {
+				t.Errorf("Expected no Access-Control-Allow-Origin header but got %q", respOrigin)
+			}
+		})
+	}
+}
+
+func TestCorsAllowOriginPatternsValidation(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		expected error
+	}{
+		{`https://[a-z]+\.domain\.com`, nil},
+		{`https://(a|b)\.domain\.com`, nil},
+		{`https?://[a-z]+\.domain\.com(:[0-9]+)?`, nil},
+		{`https://[a-z]+\.domain\.com:[0-9]{4}`, nil},
+		{`^https://(?i)[a-z]+\.Domain\.com$`, nil},
+		{`https://(app|admin)\.example\.com|https://[a-z]+\.domain\.com`, nil},
+		{`http://localhost:[0-9]+`, nil},
+		{`http://192\.168\.[0-9]{1,3}\.[0-9]{1,3}`, nil},
+		{`https://tenant-[a-z0-9]+.domain.com`, ErrOriginPatternTooBroad}, // unescaped dots
+		{`https://\w+\.\w+`, ErrOriginPatternTooBroad},
+		{`https?://[a-z]+(\.[a-z]+)*`, ErrOriginPatternTooBroad},
+		{`https://[^/]+\.com`, ErrOriginPatternTooBroad},
+		{`https://[a-z0-9-]+\.com`, ErrOriginPatternTooBroad},
+		{`https://[a-z]+domain\.com`, ErrOriginPatternTooBroad}, // not on a label boundary
+		{`https://[a-z]+\.domain\.com[a-z]*`, ErrOriginPatternTooBroad},
+		{`https://[a-z]+\.domain\.com(:)?[0-9]+`, ErrOriginPatternTooBroad},
+		{`https://[a-z]+\.domain\.com|https://[a-z]+\.com`, ErrOriginPatternTooBroad},
+		{`http://[0-9.]+`, ErrOriginPatternTooBroad},
+		{`http://10\.[0-9]+\.[0-9]+\.[0-9]+`, ErrOriginPatternTooBroad},
+		{`null`, ErrOriginPatternTooBroad},
+		{`https://.*\.domain\.com`, ErrOriginPatternTooBroad},
+		{`https?://[a-z.-]+`, ErrOriginPatternTooBroad},
+		{`[^/]+`, ErrOriginPatternTooBroad},
+		{`https://[a-z]+\.domain\.com|https://[^/]+`, ErrOriginPatternTooBroad}, // alternation is anchored as a whole
+		{`https://[a-z+\.domain\.com`, ErrInvalidOriginPattern},
+		{"https://" + strings.Repeat("a", 300), ErrInvalidOriginPattern},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			err := Config{AllowOriginPatterns: []string{tt.pattern}}.Validate()
+			if tt.expected == nil {
+				if err != nil {
+					t.Errorf("Expected no error but got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v but got %v", tt.expected, err)
+			}
+		})
+	}
+
+	patterns := make([]string, maxOriginPatterns+1)
+	for i := range patterns {
+		patterns[i] = `https://a\.domain\.com`
+	}
+	if err := (Config{AllowOriginPatterns: patterns}).Validate(); !errors.Is(err, ErrTooManyOriginPatterns) {
+		t.Errorf("Expected %v but got %v", ErrTooManyOriginPatterns, err)
+	}
+}
+
+func TestCorsEchoHeaders(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		requestHeaders string
+		expected       string
+	}{
+		{
+			name:           "safe headers are echoed",
+			requestHeaders: "Content-Type, X-Tenant-Id",
+			expected:       "content-type",
+		},
+		{
+			name:           "allowlisted header is echoed",
+			config:         Config{EchoHeaderAllowlist: []string{"X-Tenant-Id"}},
+			requestHeaders: "Content-Type, X-Tenant-Id",
+			expected:       "content-type, x-tenant-id",
+		},
+		{
+			name:           "denylisted safe header is not echoed",
+			config:         Config{EchoHeaderDenylist: []string{"Authorization"}},
+			requestHeaders: "Authorization, Content-Type",
+			expected:       "content-type",
+		},
+		{
+			name:           "denylist beats allowlist",
+			config:         Config{EchoHeaderAllowlist: []string{"X-Tenant-Id"}, EchoHeaderDenylist: []string{"x-tenant-id"}},
+			requestHeaders: "X-Tenant-Id",
+			expected:       "",
+		},
+		{
+			name:           "unknown headers are echoed without strict mode",
+			requestHeaders: "X-Custom-Header, X-Other",
+			expected:       "x-custom-header, x-other",
+		},
+		{
+			name:           "forbidden headers are never echoed",
+			requestHeaders: "X-Custom-Header, Host, Cookie, Proxy-Authorization, Sec-Fetch-Mode, Content-Length",
+			expected:       "x-custom-header",
+		},
+		{
+			name:           "denylisted headers are not echoed in the fallback",
+			config:         Config{EchoHeaderDenylist: []string{"X-Internal"}},
+			requestHeaders: "X-Custom-Header, X-Internal",
+			expected:       "x-custom-header",
+		},
+		{
+			name:           "strict mode never echoes unknown headers",
+			config:         Config{StrictEchoHeaders: true},
+			requestHeaders: "X-Custom-Header",
+			expected:       "",
+		},
+		{
+			name:           "strict mode echoes listed headers",
+			config:         Config{StrictEchoHeaders: true, EchoHeaderAllowlist: []string{"X-Tenant-Id"}},
+			requestHeaders: "X-Tenant-Id, X-Custom-Header",
+			expected:       "x-tenant-id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.AllowOrigins = "https://example.com"
+
+			app := fiber.New()
+			app.Use(New(tt.config))
+
+			req := httptest.NewRequest("OPTIONS", "/", nil)
+			req.Header.Set("Origin", "https://example.com")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", tt.requestHeaders)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			headers := resp.Header.Get("Access-Control-Allow-Headers")
+			if headers != tt.expected {
+				t.Errorf("Expected Access-Control-Allow-Headers to be %q but got %q", tt.expected, headers)
+			}
+		})
+	}
+}
+
+func TestCorsNullOrigin(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		expectedOrigin string
+	}{
+		{
+			name:   "denied by default",
+			config: Config{AllowOrigins: "https://example.com"},
+		},
+		{
+			name:   "not matched by wildcard",
+			config: Config{AllowOrigins: "*"},
+		},
+		{
+			name:   "not matched by AllowAllOrigins",
+			config: Config{AllowAllOrigins: true},
+		},
+		{
+			name:   "not matched by wildcard patterns",
+			config: Config{AllowOrigins: "https://*.domain.com, http://localhost:*"},
+		},
+		{
+			name: "not passed to AllowOriginsFunc",
+			config: Config{AllowOriginsFunc: func(origin string, c *fiber.Ctx) (bool, error) {
+				return true, nil
+			}},
+		},
+		{
+			name:           "allowed explicitly",
+			config:         Config{AllowOrigins: "https://example.com", AllowNullOrigin: true},
+			expectedOrigin: "null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(New(tt.config))
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			for _, method := range []string{"GET", "OPTIONS"} {
+				req := httptest.NewRequest(method, "/", nil)
+				req.Header.Set("Origin", "null")
+				req.Header.Set("Access-Control-Request-Method", "GET")
+
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("Failed to test request: %v", err)
+				}
+
+				origin := resp.Header.Get("Access-Control-Allow-Origin")
+				if origin != tt.expectedOrigin {
+					t.Errorf("%s: expected Access-Control-Allow-Origin to be %q but got %q", method, tt.expectedOrigin, origin)
+				}
+			}
+		})
+	}
+}
+
+func TestCorsWildcardResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         Config
+		method         string
+		origin         string
+		requestHeaders map[string]string
+		expected       map[string]string
+		expectedStatus int
+	}{
+		{
+			name:   "simple request",
+			config: Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard},
+			method: "GET",
+			origin: "https://example.com",
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Allow-Methods":  "*",
+				"Access-Control-Allow-Headers":  "*",
+				"Access-Control-Expose-Headers": "*",
+				"Vary":                          "",
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:   "request without origin gets the same headers",
+			config: Config{AllowOrigins: "*", ResponseMode: ResponseWildcard},
+			method: "GET",
+			expected: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+				"Vary":                        "",
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:   "null origin gets the same headers regardless of AllowNullOrigin",
+			config: Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard},
+			method: "GET",
+			origin: "null",
+			expected: map[string]string{
+				"Access-Control-Allow-Origin": "*",
+				"Vary":                        "",
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:   "configured lists are sent as configured",
+			config: Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard, AllowMethods: "GET, HEAD", AllowHeaders: "Content-Type", ExposeHeaders: "X-Custom"},
+			method: "GET",
+			origin: "https://example.com",
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":   "*",
+				"Access-Control-Allow-Methods":  "GET, HEAD",
+				"Access-Control-Allow-Headers":  "Content-Type",
+				"Access-Control-Expose-Headers": "X-Custom",
+			},
+			expectedStatus: 200,
+		},
+		{
+			name:   "preflight does not echo request headers",
+			config: Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard, MaxAge: 600},
+			method: "OPTIONS",
+			origin: "https://example.com",
+			requestHeaders: map[string]string{
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "X-Custom-Header",
+			},
+			expected: map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Headers": "*",
+				"Access-Control-Max-Age":       "600",
+				"Vary":                         "",
+			},
+			expectedStatus: 204,
+		},
+		{
+			name:   "strict preflight varies on the request method",
+			config: Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard, AllowMethods: "GET", StrictPreflight: true},
+			method: "OPTIONS",
+			origin: "https://example.com",
+			requestHeaders: map[string]string{
+				"Access-Control-Request-Method": "DELETE",
+			},
+			expected: map[string]string{
+				"Access-Control-Allow-Origin": "",
+				"Vary":                        "Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+			expectedStatus: 403,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(New(tt.config))
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			for key, value := range tt.requestHeaders {
+				req.Header.Set(key, value)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d but got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			for header, value := range tt.expected {
+				if got := resp.Header.Get(header); got != value {
+					t.Errorf("Expected %s to be %q but got %q", header, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		expected []error
+	}{
+		{
+			name: "valid configuration",
+			config: Config{
+				AllowOrigins:     "https://example.com, http://localhost:3000, https://*.domain.com, http://[::1]:8080",
+				AllowCredentials: true,
+				AllowHeaders:     "Origin, Content-Type, X-CSRF-Token",
+				ExposeHeaders:    "X-Custom",
+				AllowMethods:     "GET, POST, PUT, DELETE, OPTIONS, PATCH, HEAD",
+				MaxAge:           86400,
+			},
+		},
+		{
+			name:     "credentials with wildcard origin",
+			config:   Config{AllowOrigins: "*", AllowCredentials: true},
+			expected: []error{ErrCredentialsWithWildcard},
+		},
+		{
+			name:     "credentials with wildcard in Origins",
+			config:   Config{Origins: []string{"https://example.com", "*"}, AllowCredentials: true},
+			expected: []error{ErrCredentialsWithWildcard},
+		},
+		{
+			name:     "credentials with AllowAllOrigins",
+			config:   Config{AllowAllOrigins: true, AllowCredentials: true},
+			expected: []error{ErrCredentialsWithWildcard},
+		},
+		{
+			name:     "invalid origin syntax",
+			config:   Config{AllowOrigins: "example.com, https://, https://exa mple.com"},
+			expected: []error{ErrInvalidOrigin, ErrInvalidOrigin, ErrInvalidOrigin},
+		},
+		{
+			name:     "origins with paths, queries or fragments",
+			config:   Config{AllowOrigins: "https://example.com/app, https://example.com?x=1, https://example.com/#top"},
+			expected: []error{ErrOriginPath, ErrOriginPath, ErrOriginPath},
+		},
+		{
+			name:     "origins that normalize to the same value",
+			config:   Config{AllowOrigins: "https://example.com, https://example.com:443/, https://bücher.example, https://xn--bcher-kva.example"},
+			expected: []error{ErrDuplicate, ErrDuplicate},
+		},
+		{
+			name:     "unknown methods",
+			config:   Config{AllowMethods: "GET, FETCH, get"},
+			expected: []error{ErrUnknownMethod, ErrUnknownMethod},
+		},
+		{
+			name: "duplicate entries",
+			config: Config{
+				AllowOrigins: "https://example.com, https://EXAMPLE.com, https://*.domain.com, https://*.domain.com",
+				AllowMethods: "GET, GET",
+				AllowHeaders: "Content-Type, content-type",
+			},
+			expected: []error{ErrDuplicate, ErrDuplicate, ErrDuplicate, ErrDuplicate},
+		},
+		{
+			name:     "invalid header tokens",
+			config:   Config{AllowHeaders: "Content Type, X-Ok", ExposeHeaders: "X:Bad"},
+			expected: []error{ErrInvalidHeader, ErrInvalidHeader},
+		},
+		{
+			name:     "negative MaxAge",
+			config:   Config{MaxAge: -1},
+			expected: []error{ErrNegativeMaxAge},
+		},
+		{
+			name:     "negative LogSampling",
+			config:   Config{LogSampling: -1},
+			expected: []error{ErrNegativeLogSampling},
+		},
+		{
+			name: "slice fields drop duplicates but report invalid entries",
+			config: Config{
+				Origins: []string{"https://example.com", "https://EXAMPLE.com", "example.com"},
+				Methods: []string{"GET", "get", "BREW"},
+				Headers: []string{"Content-Type", "content-type", "Bad Header"},
+				Expose:  []string{"X-Custom", "x-custom"},
+			},
+			expected: []error{ErrInvalidOrigin, ErrUnknownMethod, ErrInvalidHeader},
+		},
+		{
+			name: "forbidden or invalid echo headers",
+			config: Config{
+				EchoHeaderAllowlist: []string{"X-Tenant-Id", "Cookie", "Sec-Fetch-Site", "Bad Header"},
+				EchoHeaderDenylist:  []string{"Bad:Header"},
+			},
+			expected: []error{ErrForbiddenHeader, ErrForbiddenHeader, ErrInvalidHeader, ErrInvalidHeader},
+		},
+		{
+			name:     "null origin in AllowOrigins",
+			config:   Config{AllowOrigins: "https://example.com, null"},
+			expected: []error{ErrNullOrigin},
+		},
+		{
+			name:     "wildcard response without allowing all origins",
+			config:   Config{AllowOrigins: "https://example.com", ResponseMode: ResponseWildcard},
+			expected: []error{ErrWildcardResponse},
+		},
+		{
+			name:     "wildcard response with credentials",
+			config:   Config{AllowAllOrigins: true, AllowCredentials: true, ResponseMode: ResponseWildcard},
+			expected: []error{ErrCredentialsWithWildcard},
+		},
+		{
+			name:     "unknown response mode",
+			config:   Config{ResponseMode: 7},
+			expected: []error{ErrUnknownResponseMode},
+		},
+		{
+			name:     "invalid skip path pattern",
+			config:   Config{SkipPaths: []string{"/ok/**", "/bad/["}},
+			expected: []error{ErrInvalidPathPattern},
+		},
+		{
+			name:     "non-error preflight failure status",
+			config:   Config{StrictPreflight: true, PreflightFailureStatus: 204},
+			expected: []error{ErrInvalidStatus},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if len(tt.expected) == 0 {
+				if err != nil {
+					t.Fatalf("Expected no error but got %v", err)
+				}
+				return
+			}
+
+			var configErr *ConfigError
+			if !errors.As(err, &configErr) {
+				t.Fatalf("Expected a *ConfigError but got %v", err)
+			}
+			if len(configErr.Errors) != len(tt.expected) {
+				t.Fatalf("Expected %d problems but got %d: %v", len(tt.expected), len(configErr.Errors), err)
+			}
+			for i, expected := range tt.expected {
+				if !errors.Is(configErr.Errors[i], expected) {
+					t.Errorf("Expected problem %d to be %v but got %v", i, expected, configErr.Errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNewWithError(t *testing.T) {
+	handler, err := NewWithError(Config{
+		AllowOrigins: "https://example.com/app, ftp//bad",
+		AllowMethods: "GET, BREW",
+		MaxAge:       -5,
+	})
+	if handler != nil {
+		t.Error("Expected no handler for an invalid configuration")
+	}
+	if !errors.Is(err, ErrOriginPath) || !errors.Is(err, ErrInvalidOrigin) ||
+		!errors.Is(err, ErrUnknownMethod) || !errors.Is(err, ErrNegativeMaxAge) {
+		t.Errorf("Expected every problem to be reported but got %v", err)
+	}
+
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "AllowOrigins" || fieldErr.Value != "https://example.com/app" {
+		t.Errorf("Expected first problem to name the AllowOrigins entry but got %+v", fieldErr)
+	}
+
+	handler, err = NewWithError(Config{AllowOrigins: "https://example.com"})
+	if err != nil || handler == nil {
+		t.Fatalf("Expected a handler for a valid configuration but got error %v", err)
+	}
+}
+
+func TestCorsWithMultipleMiddleware(t *testing.T) {
+	app := fiber.New()
+
+	corsConfig := Config{
+		AllowOrigins:     "https://example.com",
+		AllowCredentials: true,
+		AllowHeaders:     "Content-Type",
+		ExposeHeaders:    "X-Custom",
+		AllowMethods:     "GET, POST",
+	}
+
+	middlewareExecuted := false
+
+	app.Use(func(c *fiber.Ctx) error {
+		middlewareExecuted = true
+		return c.Next()
+	})
+
+	app.Use(New(corsConfig))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://example.com")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+
+	if !middlewareExecuted {
+		t.Error("Expected middleware to be executed")
+	}
+
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected status 200 but got %d", resp.StatusCode)
+	}
+
+	origin := resp.Header.Get("Access-Control-Allow-Origin")
+	if origin != "https://example.com" {
+		t.Errorf("Expected Access-Control-Allow-Origin to be 'https://example.com' but got %q", origin)
+	}
+}
+
+func TestCorsWithWildcardOrigin(t *testing.T) {
+	app := fiber.New()
+
+	corsConfig := Config{
+		AllowOrigins:     "*",
+		AllowCredentials: false, // Must be false with wildcard origin
+		AllowHeaders:     "Content-Type",
+		ExposeHeaders:    "X-Custom",
+		AllowMethods:     "GET, POST",
+	}
+
+	app.Use(New(corsConfig))
+
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	testOrigins := []string{
+		"https://example.com",
+		"https://another-site.com",
+		"http://localhost:3000",
+	}
+
+	for _, origin := range testOrigins {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", origin)
+
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request with origin %s: %v", origin, err)
+		}
+
+		if resp.StatusCode != 200 {
+			t.Errorf("Expected status 200 but got %d for origin %s", resp.StatusCode, origin)
+		}
+
+		respOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+		if respOrigin != origin {
+			t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", origin, respOrigin)
+		}
+	}
+}
+
+func TestCorsWildcardOriginPatterns(t *testing.T) {
+	corsConfig := Config{
+		AllowOrigins: "https://*.preview.domain.com, http://localhost:*, https://console.domain.com",
+		AllowMethods: "GET, POST",
+	}
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://pr-123.preview.domain.com", true},
+		{"https://PR-123.Preview.Domain.com", true},
+		{"https://a.b.preview.domain.com", true},
+		{"http://localhost:3000", true},
+		{"http://localhost", true},
+		{"https://console.domain.com", true},
+		{"https://preview.domain.com", false},          // wildcard requires a subdomain label
+		{"https://.preview.domain.com", false},         // empty label
+		{"https://a..preview.domain.com", false},       // empty label
+		{"https://evil-preview.domain.com", false},     // not on a label boundary
+		{"https://preview.domain.com.evil.com", false}, // suffix appears mid-host
+		{"https://pr-1.preview.domain.com.evil.com", false},
+		{"http://pr-123.preview.domain.com", false},       // scheme mismatch
+		{"https://pr-123.preview.domain.com:8443", false}, // port mismatch
+		{"https://user@pr-123.preview.domain.com", false}, // userinfo
+		{"https://pr-123.preview.domain.com/path", false},
+		{"https://localhost:3000", false},
+		{"http://localhost:notaport", false},
+		{"http://localhost:0", false},
+		{"http://localhost.evil.com:3000", false},
+		{"http://evil.com/localhost:3000", false},
+	}
+
+	app := fiber.New()
+	app.Use(New(corsConfig))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tt.origin)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			respOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+			if tt.allowed && respOrigin != tt.origin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.origin, respOrigin)
+			}
+			if !tt.allowed && respOrigin != "" {
+				t.Errorf("Expected no Access-Control-Allow-Origin header but got %q", respOrigin)
+			}
+		})
+	}
+}
+
+func TestCorsInvalidWildcardOriginPatterns(t *testing.T) {
+	patterns := []string{
+		"https://*.com",
+		"https://foo*.domain.com",
+		"https://*.*.domain.com",
+		"https://sub.*.domain.com",
+		"*.domain.com",
+		"https://*.domain.com/path",
+		"https://*.domain.com:port",
+		"https://*",
+		"http://:*",
+		"http://exa mple:*",
+		"http://a b:*",
+		"http://%zz:*",
+		"http://example..com:*",
+	}
+
+	for _, pattern := range patterns {
+		t.Run(pattern, func(t *testing.T) {
+			if err := (Config{AllowOrigins: pattern}).Validate(); err == nil {
+				t.Errorf("Expected Validate to reject origin pattern %q", pattern)
+			}
+
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("Expected panic for origin pattern %q, but no panic occurred", pattern)
+				}
+			}()
+
+			New(Config{AllowOrigins: pattern})
+		})
+	}
+}
+
+func TestCorsAllowOriginsFunc(t *testing.T) {
+	tenantOrigins := map[string]bool{
+		"https://tenant-a.com": true,
+	}
+	errLookup := errors.New("tenant lookup failed")
+
+	var calledWith []string
+	corsConfig := Config{
+		AllowOrigins: "https://console.domain.com",
+		AllowMethods: "GET, POST",
+		AllowOriginsFunc: func(origin string, c *fiber.Ctx) (bool, error) {
+			calledWith = append(calledWith, origin)
+			if origin == "https://broken.com" {
+				return false, errLookup
+			}
+			return tenantOrigins[origin], nil
+		},
+	}
+
+	var handledErr error
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			handledErr = err
+			return c.SendStatus(500)
+		},
+	})
+	app.Use(New(corsConfig))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		origin         string
+		expectedOrigin string
+		expectedStatus int
+		expectCall     bool
+	}{
+		{"https://console.domain.com", "https://console.domain.com", 200, false}, // static list is checked first
+		{"https://tenant-a.com", "https://tenant-a.com", 200, true},
+		{"https://tenant-b.com", "", 200, true},
+		{"https://broken.com", "", 500, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			calledWith = nil
+			handledErr = nil
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tt.origin)
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d but got %d", tt.expectedStatus, resp.StatusCode)
+			}
+
+			respOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+			if respOrigin != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.expectedOrigin, respOrigin)
+			}
+
+			if tt.expectCall != (len(calledWith) == 1) {
+				t.Errorf("Expected AllowOriginsFunc called=%v but it was called with %v", tt.expectCall, calledWith)
+			}
+
+			if tt.expectedStatus == 500 && !errors.Is(handledErr, errLookup) {
+				t.Errorf("Expected error handler to receive %v but got %v", errLookup, handledErr)
+			}
+		})
+	}
+}
+
+func TestCorsAllowOriginsFuncWithEmptyAllowOrigins(t *testing.T) {
+	app := fiber.New()
+	app.Use(New(Config{
+		AllowOriginsFunc: func(origin string, c *fiber.Ctx) (bool, error) {
+			return false, nil
+		},
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://example.com")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+
+	// An empty AllowOrigins must not fall back to allowing every origin when a callback decides
+	if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("Expected no Access-Control-Allow-Origin header but got %q", origin)
+	}
+}
+
+func TestCorsVaryHeader(t *testing.T) {
+	corsConfig := Config{
+		AllowOrigins: "https://example.com",
+		AllowHeaders: "Content-Type",
+		AllowMethods: "GET, POST, OPTIONS",
+	}
+
+	preflightHeaders := map[string]string{
+		"Access-Control-Request-Method":  "POST",
+		"Access-Control-Request-Headers": "Content-Type",
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		origin         string
+		requestHeaders map[string]string
+		existingVary   string
+		expectedVary   string
+	}{
+		{
+			name:         "simple request from allowed origin",
+			method:       "GET",
+			origin:       "https://example.com",
+			expectedVary: "Origin",
+		},
+		{
+			name:         "simple request from disallowed origin",
+			method:       "GET",
+			origin:       "https://disallowed.com",
+			expectedVary: "Origin",
+		},
+		{
+			name:         "request without origin",
+			method:       "GET",
+			expectedVary: "Origin",
+		},
+		{
+			name:           "preflight from allowed origin",
+			method:         "OPTIONS",
+			origin:         "https://example.com",
+			requestHeaders: preflightHeaders,
+			expectedVary:   "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		},
+		{
+			name:           "preflight from disallowed origin",
+			method:         "OPTIONS",
+			origin:         "https://disallowed.com",
+			requestHeaders: preflightHeaders,
+			expectedVary:   "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		},
+		{
+			name:         "existing Vary values are preserved",
+			method:       "GET",
+			origin:       "https://example.com",
+			existingVary: "Accept-Encoding",
+			expectedVary: "Accept-Encoding, Origin",
+		},
+		{
+			name:         "Origin is not duplicated",
+			method:       "GET",
+			origin:       "https://example.com",
+			existingVary: "Origin",
+			expectedVary: "Origin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			if tt.existingVary != "" {
+				app.Use(func(c *fiber.Ctx) error {
+					c.Set("Vary", tt.existingVary)
+					return c.Next()
+				})
+			}
+			app.Use(New(corsConfig))
+			app.Get("/", func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			for key, value := range tt.requestHeaders {
+				req.Header.Set(key, value)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			vary := resp.Header.Get("Vary")
+			if vary != tt.expectedVary {
+				t.Errorf("Expected Vary to be %q but got %q", tt.expectedVary, vary)
+			}
+		})
+	}
+}
+
+func TestCorsMiddlewareAllocations(t *testing.T) {
+	if raceEnabled {
+		t.Skip("the race detector allocates")
+	}
+
+	app := fiber.New()
+	app.Use(New(Config{
+		AllowOrigins:     "https://example.com, https://*.example.com",
+		AllowCredentials: true,
+		MaxAge:           3600,
+	}))
+	app.All("/", func(c *fiber.Ctx) error {
+		return nil
+	})
+	handler := app.Handler()
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+	}{
+		{"allowed", "GET", map[string]string{"Origin": "https://example.com"}},
+		{"wildcard subdomain", "GET", map[string]string{"Origin": "https://app.example.com"}},
+		{"rejected", "GET", map[string]string{"Origin": "https://evil.com"}},
+		{"preflight", "OPTIONS", map[string]string{
+			"Origin":                         "https://example.com",
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "content-type,x-tenant-id",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fctx := &fasthttp.RequestCtx{}
+			fctx.Request.Header.SetMethod(tt.method)
+			fctx.Request.SetRequestURI("/")
+			for key, value := range tt.headers {
+				fctx.Request.Header.Set(key, value)
+			}
+
+			allocs := testing.AllocsPerRun(100, func() {
+				fctx.Response.Reset()
+				handler(fctx)
+			})
+			if allocs != 0 {
+				t.Errorf("Expected no allocations per request but got %v", allocs)
+			}
+		})
+	}
+}
+
+func BenchmarkCorsMiddleware(b *testing.B) {
+	benchmarks := []struct {
+		name    string
+		config  Config
+		method  string
+		headers map[string]string
+	}{
+		{
+			name: "allowed",
+			config: Config{
+				AllowOrigins:     "https://example.com, https://allowed.com",
+				AllowCredentials: true,
+				AllowHeaders:     "Content-Type",
+				ExposeHeaders:    "X-Custom",
+				AllowMethods:     "GET, POST, OPTIONS",
+			},
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://allowed.com"},
+		},
+		{
+			name: "preflight",
+			config: Config{
+				AllowOrigins:     "https://example.com, https://allowed.com",
+				AllowCredentials: true,
+				AllowHeaders:     "Content-Type",
+				AllowMethods:     "GET, POST, OPTIONS",
+				MaxAge:           3600,
+			},
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://allowed.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "Content-Type",
+			},
+		},
+		{
+			name:   "preflight echoing headers",
+			config: Config{AllowOrigins: "https://allowed.com", StrictPreflight: true, MaxAge: 3600},
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Origin":                         "https://allowed.com",
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "authorization,content-type",
+			},
+		},
+		{
+			name:    "wildcard subdomain",
+			config:  Config{AllowOrigins: "https://*.example.com"},
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://app.example.com"},
+		},
+		{
+			name:    "non-canonical origin",
+			config:  Config{AllowOrigins: "https://allowed.com"},
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://Allowed.com:443"},
+		},
+		{
+			name:    "rejected",
+			config:  Config{AllowOrigins: "https://example.com, https://*.example.com"},
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://evil.com"},
+		},
+		{
+			name:    "no origin",
+			config:  Config{AllowOrigins: "https://example.com"},
+			method:  "GET",
+			headers: map[string]string{},
+		},
+		{
+			name:    "wildcard response",
+			config:  Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard},
+			method:  "GET",
+			headers: map[string]string{"Origin": "https://anyone.com"},
+		},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			app := fiber.New()
+			app.Use(New(bm.config))
+			app.All("/", func(c *fiber.Ctx) error {
+				return nil
+			})
+			handler := app.Handler()
+
+			fctx := &fasthttp.RequestCtx{}
+			fctx.Request.Header.SetMethod(bm.method)
+			fctx.Request.SetRequestURI("/")
+			for key, value := range bm.headers {
+				fctx.Request.Header.Set(key, value)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				fctx.Response.Reset()
+				handler(fctx)
+			}
+		})
 	}
 }
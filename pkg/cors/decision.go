@@ -0,0 +1,128 @@
+package cors
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Reason explains why the middleware rejected a CORS request
+type Reason string
+
+const (
+	// ReasonOriginNotAllowed is given for origins not allowed by any setting
+	ReasonOriginNotAllowed Reason = "origin_not_allowed"
+
+	// ReasonInvalidOrigin is given for Origin headers that are not of the form
+	// scheme://host[:port] and not allowed by any setting
+	ReasonInvalidOrigin Reason = "invalid_origin"
+
+	// ReasonNullOrigin is given for "Origin: null" when AllowNullOrigin is not set
+	ReasonNullOrigin Reason = "null_origin"
+
+	// ReasonMethodNotAllowed is given for preflights rejected by StrictPreflight because
+	// the requested method is not allowed
+	ReasonMethodNotAllowed Reason = "method_not_allowed"
+
+	// ReasonHeaderNotAllowed is given for preflights rejected by StrictPreflight because
+	// a requested header is not allowed
+	ReasonHeaderNotAllowed Reason = "header_not_allowed"
+
+	// ReasonPrivateNetworkNotAllowed is given for Private Network Access preflights from
+	// origins that are not granted Private Network Access
+	ReasonPrivateNetworkNotAllowed Reason = "private_network_not_allowed"
+)
+
+// Rules reported in Decision.Rule for origins allowed by a setting rather than a list entry
+const (
+	ruleAllowAll         = "*"
+	ruleAllowNullOrigin  = "AllowNullOrigin"
+	ruleOriginStore      = "OriginStore"
+	ruleAllowOriginsFunc = "AllowOriginsFunc"
+	ruleResponseWildcard = "ResponseWildcard"
+)
+
+// Decision describes how the middleware handled a request with an Origin header
+// Like values returned by fiber.Ctx, its strings are only valid until the handler returns
+// unless Fiber's Immutable setting is used; copy them to keep them longer.
+type Decision struct {
+	// Origin is the Origin request header and NormalizedOrigin its NormalizeOrigin form
+	Origin           string
+	NormalizedOrigin string
+
+	// Preflight is set for preflight requests, which carry the requested method and headers
+	Preflight        bool
+	RequestedMethod  string
+	RequestedHeaders string
+
+	// Allowed reports whether the middleware granted the request. Preflights the browser will
+	// fail because Private Network Access was not granted count as rejected; preflights
+	// requesting methods or headers that are not allowed only do with StrictPreflight.
+	Allowed bool
+
+	// Rule is what allowed the origin: the matching AllowOrigins or AllowOriginPatterns entry,
+	// "*" for all origins, or "AllowNullOrigin", "OriginStore", "AllowOriginsFunc" or
+	// "ResponseWildcard". It is empty if the origin was rejected.
+	Rule string
+
+	// Reason is why the request was rejected, or empty if it was allowed
+	Reason Reason
+}
+
+// notify logs a decision and passes it to Metrics and the hooks
+func (p *policy) notify(c *fiber.Ctx, d Decision) error {
+	if p.config.Metrics != nil {
+		p.config.Metrics.Observe(d)
+	}
+	if p.config.Logger != nil {
+		p.logDecision(c, &d)
+	}
+	if d.Preflight && p.config.OnPreflight != nil {
+		if err := p.config.OnPreflight(c, d); err != nil {
+			return err
+		}
+	}
+	if d.Allowed {
+		if p.config.OnAllowed != nil {
+			return p.config.OnAllowed(c, d)
+		}
+	} else if p.config.OnRejected != nil {
+		return p.config.OnRejected(c, d)
+	}
+	return nil
+}
+
+// logDecision logs a decision to Config.Logger, subject to its level and LogSampling
+func (p *policy) logDecision(c *fiber.Ctx, d *Decision) {
+	level, msg := slog.LevelInfo, "CORS request rejected"
+	if d.Allowed {
+		level, msg = slog.LevelDebug, "CORS request allowed"
+	}
+	ctx := c.UserContext()
+	if !p.config.Logger.Enabled(ctx, level) {
+		return
+	}
+	if n := uint64(p.config.LogSampling); n > 1 && p.logCount.Add(1)%n != 1 {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("origin", d.Origin),
+		slog.String("normalized_origin", d.NormalizedOrigin),
+		slog.String("method", c.Method()),
+		slog.String("path", c.Path()),
+		slog.Bool("preflight", d.Preflight),
+	}
+	if d.Preflight {
+		attrs = append(attrs,
+			slog.String("requested_method", d.RequestedMethod),
+			slog.String("requested_headers", d.RequestedHeaders),
+		)
+	}
+	if d.Allowed {
+		attrs = append(attrs, slog.String("rule", d.Rule))
+	} else {
+		attrs = append(attrs, slog.String("reason", string(d.Reason)))
+	}
+	p.config.Logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,387 @@
+package cors
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// logRecords decodes the records written by a slog.JSONHandler
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to decode log record %q: %v", line, err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestCorsLogger(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		origin   string
+		method   string
+		headers  map[string]string
+		expected map[string]any
+	}{
+		{
+			name:     "allowed by exact entry",
+			config:   Config{AllowOrigins: "https://example.com:443"},
+			origin:   "https://Example.com",
+			method:   "GET",
+			expected: map[string]any{"level": "DEBUG", "msg": "CORS request allowed", "normalized_origin": "https://example.com", "rule": "https://example.com"},
+		},
+		{
+			name:     "allowed by wildcard entry",
+			config:   Config{AllowOrigins: "https://*.example.com"},
+			origin:   "https://app.example.com",
+			method:   "GET",
+			expected: map[string]any{"level": "DEBUG", "rule": "https://*.example.com", "preflight": false},
+		},
+		{
+			name:     "allowed by origin pattern",
+			config:   Config{AllowOriginPatterns: []string{`https://tenant-[0-9]+\.example\.com`}},
+			origin:   "https://tenant-1.example.com",
+			method:   "GET",
+			expected: map[string]any{"rule": `https://tenant-[0-9]+\.example\.com`},
+		},
+		{
+			name:     "allowed by AllowOriginsFunc",
+			config:   Config{AllowOriginsFunc: func(string, *fiber.Ctx) (bool, error) { return true, nil }},
+			origin:   "https://example.com",
+			method:   "GET",
+			expected: map[string]any{"rule": "AllowOriginsFunc"},
+		},
+		{
+			name:     "rejected origin",
+			config:   Config{AllowOrigins: "https://example.com"},
+			origin:   "https://evil.com",
+			method:   "GET",
+			expected: map[string]any{"level": "INFO", "msg": "CORS request rejected", "reason": "origin_not_allowed", "method": "GET", "path": "/"},
+		},
+		{
+			name:     "invalid origin",
+			config:   Config{AllowOrigins: "https://example.com"},
+			origin:   "https://example.com/path",
+			method:   "GET",
+			expected: map[string]any{"reason": "invalid_origin"},
+		},
+		{
+			name:     "null origin",
+			config:   Config{AllowAllOrigins: true},
+			origin:   "null",
+			method:   "GET",
+			expected: map[string]any{"reason": "null_origin"},
+		},
+		{
+			name:   "preflight with method not allowed",
+			config: Config{AllowOrigins: "https://example.com", AllowMethods: "GET", StrictPreflight: true},
+			origin: "https://example.com",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Access-Control-Request-Method":  "DELETE",
+				"Access-Control-Request-Headers": "x-custom",
+			},
+			expected: map[string]any{"reason": "method_not_allowed", "preflight": true, "requested_method": "DELETE", "requested_headers": "x-custom"},
+		},
+		{
+			name:   "preflight with header not allowed",
+			config: Config{AllowOrigins: "https://example.com", AllowHeaders: "Content-Type", StrictPreflight: true},
+			origin: "https://example.com",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Access-Control-Request-Method":  "POST",
+				"Access-Control-Request-Headers": "x-custom",
+			},
+			expected: map[string]any{"reason": "header_not_allowed"},
+		},
+		{
+			name:   "private network not allowed",
+			config: Config{AllowOrigins: "https://example.com"},
+			origin: "https://example.com",
+			method: "OPTIONS",
+			headers: map[string]string{
+				"Access-Control-Request-Method":          "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			expected: map[string]any{"reason": "private_network_not_allowed"},
+		},
+		{
+			name:     "wildcard response",
+			config:   Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard},
+			origin:   "https://example.com",
+			method:   "GET",
+			expected: map[string]any{"level": "DEBUG", "rule": "ResponseWildcard"},
+		},
+		{
+			name:     "wildcard response ignores AllowNullOrigin",
+			config:   Config{AllowAllOrigins: true, ResponseMode: ResponseWildcard},
+			origin:   "null",
+			method:   "GET",
+			expected: map[string]any{"level": "DEBUG", "rule": "ResponseWildcard"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.config.Logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			app := fiber.New()
+			app.Use(New(tt.config))
+			app.All("/", func(c *fiber.Ctx) error {
+				return c.SendStatus(200)
+			})
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			for key, value := range tt.headers {
+				req.Header.Set(key, value)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			records := logRecords(t, &buf)
+			if len(records) != 1 {
+				t.Fatalf("Expected 1 log record but got %d: %s", len(records), buf.String())
+			}
+			if records[0]["origin"] != tt.origin {
+				t.Errorf("Expected origin %q but got %v", tt.origin, records[0]["origin"])
+			}
+			for key, value := range tt.expected {
+				if records[0][key] != value {
+					t.Errorf("Expected %s to be %v but got %v", key, value, records[0][key])
+				}
+			}
+		})
+	}
+}
+
+func TestCorsLoggerLevelsAndSampling(t *testing.T) {
+	var buf bytes.Buffer
+	app := fiber.New()
+	app.Use(New(Config{
+		AllowOrigins: "https://example.com",
+		Logger:       slog.New(slog.NewJSONHandler(&buf, nil)),
+		LogSampling:  3,
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	send := func(origin string) {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+	}
+
+	// Allowed requests are logged at debug level, which the default handler does not enable,
+	// and requests without an Origin are not CORS requests
+	for i := 0; i < 5; i++ {
+		send("https://example.com")
+		send("")
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Expected no log records but got %s", buf.String())
+	}
+
+	for i := 0; i < 7; i++ {
+		send("https://evil.com")
+	}
+	if records := logRecords(t, &buf); len(records) != 3 {
+		t.Errorf("Expected 3 of 7 rejections to be logged but got %d", len(records))
+	}
+}
+
+func TestCorsDecisionHooks(t *testing.T) {
+	var calls []string
+	record := func(name string) func(*fiber.Ctx, Decision) error {
+		return func(c *fiber.Ctx, d Decision) error {
+			calls = append(calls, name+" "+d.Origin+" "+string(d.Reason))
+			return nil
+		}
+	}
+
+	app := fiber.New()
+	app.Use(New(Config{
+		AllowOrigins:    "https://example.com",
+		AllowMethods:    "GET, POST",
+		StrictPreflight: true,
+		OnAllowed:       record("allowed"),
+		OnRejected:      record("rejected"),
+		OnPreflight:     record("preflight"),
+	}))
+	app.All("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		method          string
+		origin          string
+		requestedMethod string
+		expected        []string
+	}{
+		{method: "GET", origin: "https://example.com", expected: []string{"allowed https://example.com "}},
+		{method: "GET", origin: "https://evil.com", expected: []string{"rejected https://evil.com origin_not_allowed"}},
+		{method: "GET"},
+		{method: "OPTIONS", origin: "https://example.com", requestedMethod: "POST", expected: []string{
+			"preflight https://example.com ",
+			"allowed https://example.com ",
+		}},
+		{method: "OPTIONS", origin: "https://example.com", requestedMethod: "DELETE", expected: []string{
+			"preflight https://example.com method_not_allowed",
+			"rejected https://example.com method_not_allowed",
+		}},
+	}
+
+	for _, tt := range tests {
+		calls = nil
+		req := httptest.NewRequest(tt.method, "/", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.requestedMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", tt.requestedMethod)
+		}
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if strings.Join(calls, "; ") != strings.Join(tt.expected, "; ") {
+			t.Errorf("%s from %q: expected hooks %q but got %q", tt.method, tt.origin, tt.expected, calls)
+		}
+	}
+}
+
+func TestCorsOnRejectedResponse(t *testing.T) {
+	tests := []struct {
+		name           string
+		onRejected     func(*fiber.Ctx, Decision) error
+		strict         bool
+		origin         string
+		method         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "default preflight rejection",
+			onRejected:     func(*fiber.Ctx, Decision) error { return nil },
+			strict:         true,
+			origin:         "https://evil.com",
+			method:         "OPTIONS",
+			expectedStatus: 403,
+			expectedBody:   "Forbidden",
+		},
+		{
+			name: "custom preflight rejection",
+			onRejected: func(c *fiber.Ctx, d Decision) error {
+				return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": string(d.Reason)})
+			},
+			strict:         true,
+			origin:         "https://evil.com",
+			method:         "OPTIONS",
+			expectedStatus: 401,
+			expectedBody:   `{"error":"origin_not_allowed"}`,
+		},
+		{
+			name: "custom body with the default status",
+			onRejected: func(c *fiber.Ctx, d Decision) error {
+				return c.SendString("origin not allowed")
+			},
+			strict:         true,
+			origin:         "https://evil.com",
+			method:         "OPTIONS",
+			expectedStatus: 403,
+			expectedBody:   "origin not allowed",
+		},
+		{
+			name:           "default preflight rejection without StrictPreflight",
+			onRejected:     func(*fiber.Ctx, Decision) error { return nil },
+			origin:         "https://evil.com",
+			method:         "OPTIONS",
+			expectedStatus: 204,
+		},
+		{
+			name: "custom preflight rejection without StrictPreflight",
+			onRejected: func(c *fiber.Ctx, d Decision) error {
+				return c.Status(fiber.StatusUnauthorized).SendString("origin not allowed")
+			},
+			origin:         "https://evil.com",
+			method:         "OPTIONS",
+			expectedStatus: 401,
+			expectedBody:   "origin not allowed",
+		},
+		{
+			name: "error stops a rejected request",
+			onRejected: func(*fiber.Ctx, Decision) error {
+				return fiber.ErrTooManyRequests
+			},
+			origin:         "https://evil.com",
+			method:         "GET",
+			expectedStatus: 429,
+			expectedBody:   "Too Many Requests",
+		},
+		{
+			name:           "rejected requests reach the route by default",
+			onRejected:     func(*fiber.Ctx, Decision) error { return nil },
+			origin:         "https://evil.com",
+			method:         "GET",
+			expectedStatus: 200,
+			expectedBody:   "ok",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(New(Config{
+				AllowOrigins:    "https://example.com",
+				StrictPreflight: tt.strict,
+				OnRejected:      tt.onRejected,
+			}))
+			app.All("/", func(c *fiber.Ctx) error {
+				return c.SendString("ok")
+			})
+
+			req := httptest.NewRequest(tt.method, "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method == "OPTIONS" {
+				req.Header.Set("Access-Control-Request-Method", "GET")
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d but got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.expectedBody {
+				t.Errorf("Expected body %q but got %q", tt.expectedBody, body)
+			}
+			if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != "" {
+				t.Errorf("Expected no Access-Control-Allow-Origin header but got %q", origin)
+			}
+		})
+	}
+}
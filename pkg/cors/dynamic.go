@@ -0,0 +1,61 @@
+package cors
+
+import (
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Dynamic is a CORS middleware whose configuration can be replaced while the application is
+// serving requests, e.g. to add customer origins from an admin panel without a redeploy
+//
+//	d, err := cors.NewDynamic(config)
+//	app.Use(d.Handler())
+//	...
+//	err = d.Update(newConfig)
+type Dynamic struct {
+	policy atomic.Pointer[policy]
+}
+
+// NewDynamic creates a Dynamic middleware with an initial configuration
+// If the configuration is invalid it returns a *ConfigError listing every problem found
+func NewDynamic(config Config) (*Dynamic, error) {
+	d := &Dynamic{}
+	if err := d.Update(config); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Handler returns the middleware handler, which applies the configuration most recently
+// passed to Update. It only loads the current policy, so requests never wait on a lock.
+// A zero Dynamic passes requests to the next handler until Update succeeds.
+func (d *Dynamic) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p := d.policy.Load()
+		if p == nil {
+			return c.Next()
+		}
+		return p.handle(c)
+	}
+}
+
+// Update validates and compiles a configuration and atomically replaces the current one
+// If the configuration is invalid it returns a *ConfigError and the current one is kept
+// Requests already being handled finish with the configuration they started with
+func (d *Dynamic) Update(config Config) error {
+	p, err := compile(config)
+	if err != nil {
+		return err
+	}
+	d.policy.Store(p)
+	return nil
+}
+
+// Config returns the configuration currently in use
+func (d *Dynamic) Config() Config {
+	if p := d.policy.Load(); p != nil {
+		return p.config
+	}
+	return Config{}
+}
@@ -0,0 +1,129 @@
+package cors
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestDynamic(t *testing.T) {
+	d, err := NewDynamic(Config{AllowOrigins: "https://console.domain.com"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(d.Handler())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	allowedOrigin := func(origin string) string {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", origin)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		return resp.Header.Get("Access-Control-Allow-Origin")
+	}
+
+	if got := allowedOrigin("https://customer.com"); got != "" {
+		t.Errorf("Expected customer origin to be rejected before Update but got %q", got)
+	}
+
+	if err := d.Update(Config{AllowOrigins: "https://console.domain.com, https://customer.com"}); err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	if got := allowedOrigin("https://customer.com"); got != "https://customer.com" {
+		t.Errorf("Expected customer origin to be allowed after Update but got %q", got)
+	}
+
+	// An invalid configuration is rejected and the current one is kept
+	err = d.Update(Config{AllowOrigins: "https://customer.com/app"})
+	if !errors.Is(err, ErrOriginPath) {
+		t.Errorf("Expected ErrOriginPath but got %v", err)
+	}
+	if got := allowedOrigin("https://customer.com"); got != "https://customer.com" {
+		t.Errorf("Expected customer origin to stay allowed after a failed Update but got %q", got)
+	}
+	if origins := d.Config().AllowOrigins; origins != "https://console.domain.com, https://customer.com" {
+		t.Errorf("Expected Config to return the current configuration but got %q", origins)
+	}
+}
+
+func TestNewDynamicWithError(t *testing.T) {
+	d, err := NewDynamic(Config{MaxAge: -1})
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a *ConfigError but got %v", err)
+	}
+	if d != nil {
+		t.Errorf("Expected no Dynamic for an invalid configuration")
+	}
+}
+
+func TestDynamicZeroValue(t *testing.T) {
+	var d Dynamic
+
+	app := fiber.New()
+	app.Use(d.Handler())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://example.com")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get("Vary") != "" {
+		t.Errorf("Expected the request to pass through without CORS processing")
+	}
+}
+
+func TestDynamicConcurrentUpdate(t *testing.T) {
+	d, err := NewDynamic(Config{AllowOrigins: "https://a.com"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	app := fiber.New()
+	app.Use(d.Handler())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			origins := "https://a.com"
+			if i%2 == 0 {
+				origins = "https://a.com, https://b.com"
+			}
+			if err := d.Update(Config{AllowOrigins: origins}); err != nil {
+				t.Errorf("Expected no error but got %v", err)
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", "https://a.com")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if got := resp.Header.Get("Access-Control-Allow-Origin"); got != "https://a.com" {
+			t.Fatalf("Expected https://a.com to stay allowed during updates but got %q", got)
+		}
+	}
+	wg.Wait()
+}
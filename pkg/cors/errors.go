@@ -0,0 +1,157 @@
+package cors
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors reported by Config.Validate and NewWithError. Each problem is returned as a
+// *FieldError wrapping one of these, so callers can test for them with errors.Is
+var (
+	// ErrInvalidOrigin is reported for origins that are not of the form scheme://host[:port]
+	ErrInvalidOrigin = errors.New("invalid origin")
+
+	// ErrOriginPath is reported for origins that include a path, query or fragment
+	// A single trailing slash is accepted and removed by NormalizeOrigin
+	ErrOriginPath = errors.New("origin must not have a path, query or fragment")
+
+	// ErrNullOrigin is reported for "null" entries in AllowOrigins; use AllowNullOrigin instead
+	ErrNullOrigin = errors.New("the null origin must be allowed with AllowNullOrigin")
+
+	// ErrUnknownMethod is reported for methods that are not standard HTTP methods
+	ErrUnknownMethod = errors.New("unknown method")
+
+	// ErrDuplicate is reported for entries that appear more than once in a list
+	ErrDuplicate = errors.New("duplicate entry")
+
+	// ErrInvalidHeader is reported for header names that are not valid HTTP tokens
+	ErrInvalidHeader = errors.New("invalid header name")
+
+	// ErrNegativeMaxAge is reported when MaxAge is below zero
+	ErrNegativeMaxAge = errors.New("must not be negative")
+
+	// ErrNegativeLogSampling is reported when LogSampling is below zero
+	ErrNegativeLogSampling = errors.New("must not be negative")
+
+	// ErrInvalidStatus is reported for failure status codes outside the 4xx and 5xx ranges
+	ErrInvalidStatus = errors.New("must be a 4xx or 5xx status code")
+
+	// ErrInvalidPathPattern is reported for SkipPaths entries that are not valid path.Match patterns
+	ErrInvalidPathPattern = errors.New("invalid path pattern")
+
+	// ErrEmptyPolicy is reported for a Policy with no Name, Prefix or Methods
+	ErrEmptyPolicy = errors.New("policy must set Name, Prefix or Methods")
+
+	// ErrInvalidOriginPattern is reported for AllowOriginPatterns entries that are not valid
+	// regular expressions or exceed maxOriginPatternLength
+	ErrInvalidOriginPattern = errors.New("invalid origin pattern")
+
+	// ErrOriginPatternTooBroad is reported for AllowOriginPatterns entries that could match
+	// arbitrary hosts
+	ErrOriginPatternTooBroad = errors.New("origin pattern matches arbitrary hosts")
+
+	// ErrTooManyOriginPatterns is reported when AllowOriginPatterns exceeds maxOriginPatterns
+	ErrTooManyOriginPatterns = errors.New("too many origin patterns")
+
+	// ErrForbiddenHeader is reported for echo allowlist entries that are forbidden request
+	// headers such as Host, Cookie, Proxy-* and Sec-*
+	ErrForbiddenHeader = errors.New("forbidden request header")
+
+	// ErrWildcardResponse is reported when ResponseWildcard is used without allowing all origins
+	ErrWildcardResponse = errors.New("ResponseWildcard requires AllowAllOrigins or AllowOrigins=*")
+
+	// ErrUnknownResponseMode is reported for ResponseMode values other than the defined constants
+	ErrUnknownResponseMode = errors.New("unknown response mode")
+
+	// ErrCredentialsWithWildcard is reported when AllowCredentials is combined with allowing
+	// all origins through AllowAllOrigins or a * entry in AllowOrigins or Origins
+	// According to spec section 3.2.5: If credentials mode is "include",
+	// then Access-Control-Allow-Origin cannot be *
+	ErrCredentialsWithWildcard = errors.New("AllowCredentials=true is incompatible with allowing all origins")
+)
+
+// Errors reported by LoadConfig and ConfigFromEnv before a Config is validated
+var (
+	// ErrUnsupportedFormat is returned by LoadConfig for files that are not YAML, JSON or TOML
+	ErrUnsupportedFormat = errors.New("unsupported config file format")
+
+	// ErrInvalidValue is reported by ConfigFromEnv for values that cannot be parsed
+	ErrInvalidValue = errors.New("invalid value")
+)
+
+// FieldError describes a single problem with a Config field
+type FieldError struct {
+	// Field is the name of the Config field, e.g. "AllowOrigins"
+	Field string
+
+	// Value is the offending list entry, if the problem is with a single entry
+	Value string
+
+	// Err is one of the Err* values above, possibly wrapped with more detail
+	Err error
+}
+
+// Error implements the error interface
+func (e *FieldError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %v", e.Field, e.Err)
+	}
+	return fmt.Sprintf("%s %q: %v", e.Field, e.Value, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ConfigError lists every problem found while validating a Config
+type ConfigError struct {
+	Errors []*FieldError
+}
+
+// Error implements the error interface
+func (e *ConfigError) Error() string {
+	if len(e.Errors) == 1 {
+		return "CORS: " + e.Errors[0].Error()
+	}
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return "CORS: invalid configuration: " + strings.Join(messages, "; ")
+}
+
+// Unwrap returns the individual field errors so errors.Is and errors.As can inspect them
+func (e *ConfigError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// add records a problem with a Config field
+func (e *ConfigError) add(field, value string, err error) {
+	e.Errors = append(e.Errors, &FieldError{Field: field, Value: value, Err: err})
+}
+
+// addPrefixed records the problems from a nested *ConfigError, prefixing their field names
+func (e *ConfigError) addPrefixed(prefix string, err error) {
+	var nested *ConfigError
+	if !errors.As(err, &nested) {
+		e.add(strings.TrimSuffix(prefix, "."), "", err)
+		return
+	}
+	for _, fieldErr := range nested.Errors {
+		e.add(prefix+fieldErr.Field, fieldErr.Value, fieldErr.Err)
+	}
+}
+
+// err returns e if any problems were recorded, or nil otherwise
+func (e *ConfigError) err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
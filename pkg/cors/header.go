@@ -0,0 +1,150 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Response header names, in canonical form, and constant values set by the middleware
+var (
+	headerAllowOrigin         = []byte("Access-Control-Allow-Origin")
+	headerAllowCredentials    = []byte("Access-Control-Allow-Credentials")
+	headerAllowMethods        = []byte("Access-Control-Allow-Methods")
+	headerAllowHeaders        = []byte("Access-Control-Allow-Headers")
+	headerExposeHeaders       = []byte("Access-Control-Expose-Headers")
+	headerMaxAge              = []byte("Access-Control-Max-Age")
+	headerAllowPrivateNetwork = []byte("Access-Control-Allow-Private-Network")
+	headerVary                = []byte("Vary")
+
+	valueTrue     = []byte("true")
+	valueWildcard = []byte("*")
+)
+
+// setHeader sets a response header with a canonical name without any conversion
+func setHeader(c *fiber.Ctx, key, value []byte) {
+	c.Response().Header.SetCanonical(key, value)
+}
+
+// knownMethods are the HTTP methods accepted in AllowMethods
+var knownMethods = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"POST":    true,
+	"PUT":     true,
+	"DELETE":  true,
+	"CONNECT": true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PATCH":   true,
+}
+
+// safeHeaders are request headers that can be echoed back in Access-Control-Allow-Headers
+// when AllowHeaders is not configured
+var safeHeaders = map[string]bool{
+	"accept":           true,
+	"accept-language":  true,
+	"content-language": true,
+	"content-type":     true,
+	"dpr":              true,
+	"downlink":         true,
+	"save-data":        true,
+	"viewport-width":   true,
+	"width":            true,
+	"authorization":    true,
+	"x-requested-with": true,
+	"x-csrf-token":     true,
+}
+
+// forbiddenHeaders are request headers that are controlled by the browser and must never be
+// echoed back in Access-Control-Allow-Headers (Fetch standard, "forbidden request-header")
+var forbiddenHeaders = map[string]bool{
+	"accept-charset":                         true,
+	"accept-encoding":                        true,
+	"access-control-request-headers":         true,
+	"access-control-request-method":          true,
+	"access-control-request-private-network": true,
+	"connection":                             true,
+	"content-length":                         true,
+	"cookie":                                 true,
+	"cookie2":                                true,
+	"date":                                   true,
+	"dnt":                                    true,
+	"expect":                                 true,
+	"host":                                   true,
+	"keep-alive":                             true,
+	"origin":                                 true,
+	"referer":                                true,
+	"set-cookie":                             true,
+	"te":                                     true,
+	"trailer":                                true,
+	"transfer-encoding":                      true,
+	"upgrade":                                true,
+	"via":                                    true,
+}
+
+// isForbiddenHeader reports whether a lowercase header name is a forbidden request header,
+// including the "proxy-" and "sec-" prefixes
+func isForbiddenHeader(header string) bool {
+	return forbiddenHeaders[header] || strings.HasPrefix(header, "proxy-") || strings.HasPrefix(header, "sec-")
+}
+
+// splitList splits a comma-separated configuration value into trimmed, non-empty entries
+func splitList(s string) []string {
+	var list []string
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// nextHeader returns the first entry of a comma-separated header list, trimmed and lowercased,
+// and the rest of the list. Entries that are already lowercase, as browsers send them in
+// Access-Control-Request-Headers, are returned without allocating.
+func nextHeader(list string) (header, rest string) {
+	header, rest, _ = strings.Cut(list, ",")
+	return strings.ToLower(strings.TrimSpace(header)), rest
+}
+
+// trimList trims the entries of a configuration slice and drops empty ones
+func trimList(entries []string) []string {
+	var list []string
+	for _, entry := range entries {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+// canonicalHeaders trims a list of header names and converts them to canonical form
+func canonicalHeaders(headers []string) []string {
+	list := trimList(headers)
+	for i, header := range list {
+		if isToken(header) {
+			list[i] = http.CanonicalHeaderKey(header)
+		}
+	}
+	return list
+}
+
+// isToken reports whether s is a valid HTTP token (RFC 9110 section 5.6.2),
+// the syntax required for header field names and methods
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
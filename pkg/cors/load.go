@@ -0,0 +1,256 @@
+package cors
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig holds the Config settings that can be loaded from a file or the environment
+// The keys are documented on LoadConfig; Next and AllowOriginsFunc can only be set in code.
+type fileConfig struct {
+	SkipPaths                stringList   `json:"skip_paths" yaml:"skip_paths" toml:"skip_paths"`
+	AllowOrigins             stringList   `json:"allow_origins" yaml:"allow_origins" toml:"allow_origins"`
+	AllowAllOrigins          bool         `json:"allow_all_origins" yaml:"allow_all_origins" toml:"allow_all_origins"`
+	AllowOriginPatterns      patternList  `json:"allow_origin_patterns" yaml:"allow_origin_patterns" toml:"allow_origin_patterns"`
+	AllowCredentials         bool         `json:"allow_credentials" yaml:"allow_credentials" toml:"allow_credentials"`
+	AllowHeaders             stringList   `json:"allow_headers" yaml:"allow_headers" toml:"allow_headers"`
+	ExposeHeaders            stringList   `json:"expose_headers" yaml:"expose_headers" toml:"expose_headers"`
+	AllowMethods             stringList   `json:"allow_methods" yaml:"allow_methods" toml:"allow_methods"`
+	MaxAge                   int          `json:"max_age" yaml:"max_age" toml:"max_age"`
+	StrictPreflight          bool         `json:"strict_preflight" yaml:"strict_preflight" toml:"strict_preflight"`
+	PreflightFailureStatus   int          `json:"preflight_failure_status" yaml:"preflight_failure_status" toml:"preflight_failure_status"`
+	OptionsPassthrough       bool         `json:"options_passthrough" yaml:"options_passthrough" toml:"options_passthrough"`
+	PreflightContinue        bool         `json:"preflight_continue" yaml:"preflight_continue" toml:"preflight_continue"`
+	EchoHeaderAllowlist      stringList   `json:"echo_header_allowlist" yaml:"echo_header_allowlist" toml:"echo_header_allowlist"`
+	EchoHeaderDenylist       stringList   `json:"echo_header_denylist" yaml:"echo_header_denylist" toml:"echo_header_denylist"`
+	StrictEchoHeaders        bool         `json:"strict_echo_headers" yaml:"strict_echo_headers" toml:"strict_echo_headers"`
+	AllowNullOrigin          bool         `json:"allow_null_origin" yaml:"allow_null_origin" toml:"allow_null_origin"`
+	ResponseMode             ResponseMode `json:"response_mode" yaml:"response_mode" toml:"response_mode"`
+	LegacyEmptyOriginHeaders bool         `json:"legacy_empty_origin_headers" yaml:"legacy_empty_origin_headers" toml:"legacy_empty_origin_headers"`
+	AllowPrivateNetwork      bool         `json:"allow_private_network" yaml:"allow_private_network" toml:"allow_private_network"`
+	PrivateNetworkOrigins    stringList   `json:"private_network_origins" yaml:"private_network_origins" toml:"private_network_origins"`
+}
+
+// LoadConfig reads a Config from a YAML (.yaml, .yml), JSON (.json) or TOML (.toml) file and
+// validates it. Settings are top-level keys named after the Config fields in snake_case:
+//
+//	allow_origins, allow_all_origins, allow_origin_patterns, allow_credentials,
+//	allow_headers, expose_headers, allow_methods, max_age, skip_paths,
+//	strict_preflight, preflight_failure_status, options_passthrough, preflight_continue,
+//	echo_header_allowlist, echo_header_denylist, strict_echo_headers, allow_null_origin,
+//	response_mode ("echo" or "wildcard"), legacy_empty_origin_headers,
+//	allow_private_network, private_network_origins
+//
+// List settings take a list or a comma-separated string, except allow_origin_patterns,
+// whose string form is separated by whitespace since patterns may contain commas.
+// Unknown keys are an error, so typos are not silently ignored. An invalid configuration
+// is reported as a *ConfigError.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var fc fileConfig
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&fc); err != nil && !errors.Is(err, io.EOF) {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&fc); err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), &fc)
+		if err != nil {
+			return Config{}, fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return Config{}, fmt.Errorf("%s: unknown key %q", path, undecoded[0].String())
+		}
+	default:
+		return Config{}, fmt.Errorf("%s: %w %q", path, ErrUnsupportedFormat, ext)
+	}
+
+	config := fc.config()
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// ConfigFromEnv reads a Config from environment variables and validates it. Variables are
+// named after the LoadConfig keys in uppercase with prefix prepended, so
+// ConfigFromEnv("CORS_") reads CORS_ALLOW_ORIGINS, CORS_MAX_AGE and so on.
+// Lists are comma-separated, except ALLOW_ORIGIN_PATTERNS which is separated by whitespace;
+// booleans are parsed with strconv.ParseBool. Unset and empty variables are ignored.
+// Unparsable values are reported as a *ConfigError with the variable names as fields
+// and ErrInvalidValue; an invalid configuration is reported as for LoadConfig.
+func ConfigFromEnv(prefix string) (Config, error) {
+	var fc fileConfig
+	errs := &ConfigError{}
+
+	v := reflect.ValueOf(&fc).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		name := prefix + strings.ToUpper(v.Type().Field(i).Tag.Get("json"))
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if err := setEnvValue(v.Field(i), value); err != nil {
+			errs.add(name, value, ErrInvalidValue)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return Config{}, err
+	}
+
+	config := fc.config()
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// setEnvValue parses an environment variable into a fileConfig field
+func setEnvValue(field reflect.Value, value string) error {
+	switch f := field.Addr().Interface().(type) {
+	case listSetting:
+		f.set(value)
+	case encoding.TextUnmarshaler:
+		return f.UnmarshalText([]byte(value))
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		*f = b
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		*f = n
+	}
+	return nil
+}
+
+// config converts the loaded settings to a Config
+// Lists with a comma-separated Config field are joined so duplicates are reported as usual
+func (fc *fileConfig) config() Config {
+	return Config{
+		SkipPaths:                fc.SkipPaths,
+		AllowOrigins:             strings.Join(fc.AllowOrigins, ", "),
+		AllowAllOrigins:          fc.AllowAllOrigins,
+		AllowOriginPatterns:      fc.AllowOriginPatterns,
+		AllowCredentials:         fc.AllowCredentials,
+		AllowHeaders:             strings.Join(fc.AllowHeaders, ", "),
+		ExposeHeaders:            strings.Join(fc.ExposeHeaders, ", "),
+		AllowMethods:             strings.Join(fc.AllowMethods, ", "),
+		MaxAge:                   fc.MaxAge,
+		StrictPreflight:          fc.StrictPreflight,
+		PreflightFailureStatus:   fc.PreflightFailureStatus,
+		OptionsPassthrough:       fc.OptionsPassthrough,
+		PreflightContinue:        fc.PreflightContinue,
+		EchoHeaderAllowlist:      fc.EchoHeaderAllowlist,
+		EchoHeaderDenylist:       fc.EchoHeaderDenylist,
+		StrictEchoHeaders:        fc.StrictEchoHeaders,
+		AllowNullOrigin:          fc.AllowNullOrigin,
+		ResponseMode:             fc.ResponseMode,
+		LegacyEmptyOriginHeaders: fc.LegacyEmptyOriginHeaders,
+		AllowPrivateNetwork:      fc.AllowPrivateNetwork,
+		PrivateNetworkOrigins:    fc.PrivateNetworkOrigins,
+	}
+}
+
+// listSetting is a list setting that can also be given as a single string
+type listSetting interface {
+	set(s string)
+}
+
+// stringList is a list setting whose string form is comma-separated
+type stringList []string
+
+func (l *stringList) set(s string) { *l = splitList(s) }
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONList(data, (*[]string)(l), l)
+}
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalYAMLList(node, (*[]string)(l), l)
+}
+
+func (l *stringList) UnmarshalTOML(data any) error {
+	return unmarshalTOMLList(data, (*[]string)(l), l)
+}
+
+// patternList is a list setting whose string form is separated by whitespace
+type patternList []string
+
+func (l *patternList) set(s string) { *l = strings.Fields(s) }
+
+func (l *patternList) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONList(data, (*[]string)(l), l)
+}
+
+func (l *patternList) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalYAMLList(node, (*[]string)(l), l)
+}
+
+func (l *patternList) UnmarshalTOML(data any) error {
+	return unmarshalTOMLList(data, (*[]string)(l), l)
+}
+
+func unmarshalJSONList(data []byte, list *[]string, setting listSetting) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		setting.set(s)
+		return nil
+	}
+	return json.Unmarshal(data, list)
+}
+
+func unmarshalYAMLList(node *yaml.Node, list *[]string, setting listSetting) error {
+	if node.Kind == yaml.ScalarNode {
+		setting.set(node.Value)
+		return nil
+	}
+	return node.Decode(list)
+}
+
+func unmarshalTOMLList(data any, list *[]string, setting listSetting) error {
+	switch v := data.(type) {
+	case string:
+		setting.set(v)
+		return nil
+	case []any:
+		*list = make([]string, len(v))
+		for i, entry := range v {
+			s, ok := entry.(string)
+			if !ok {
+				return fmt.Errorf("expected a list of strings but got %T", entry)
+			}
+			(*list)[i] = s
+		}
+		return nil
+	}
+	return fmt.Errorf("expected a string or a list of strings but got %T", data)
+}
@@ -0,0 +1,171 @@
+package cors
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	expected := Config{
+		AllowOrigins:        "https://console.domain.com, http://localhost:3000",
+		AllowOriginPatterns: []string{`https://tenant-[a-z0-9]{1,20}\.domain\.com`},
+		AllowCredentials:    true,
+		AllowHeaders:        "Content-Type, Authorization",
+		AllowMethods:        "GET, POST",
+		MaxAge:              86400,
+		SkipPaths:           []string{"/health"},
+		StrictPreflight:     true,
+	}
+
+	files := map[string]string{
+		"cors.yaml": `
+allow_origins:
+  - https://console.domain.com
+  - http://localhost:3000
+allow_origin_patterns: https://tenant-[a-z0-9]{1,20}\.domain\.com
+allow_credentials: true
+allow_headers: Content-Type, Authorization
+allow_methods: [GET, POST]
+max_age: 86400
+skip_paths: /health
+strict_preflight: true
+`,
+		"cors.json": `{
+	"allow_origins": ["https://console.domain.com", "http://localhost:3000"],
+	"allow_origin_patterns": ["https://tenant-[a-z0-9]{1,20}\\.domain\\.com"],
+	"allow_credentials": true,
+	"allow_headers": "Content-Type, Authorization",
+	"allow_methods": ["GET", "POST"],
+	"max_age": 86400,
+	"skip_paths": "/health",
+	"strict_preflight": true
+}`,
+		"cors.toml": `
+allow_origins = "https://console.domain.com, http://localhost:3000"
+allow_origin_patterns = ['https://tenant-[a-z0-9]{1,20}\.domain\.com']
+allow_credentials = true
+allow_headers = ["Content-Type", "Authorization"]
+allow_methods = "GET, POST"
+max_age = 86400
+skip_paths = ["/health"]
+strict_preflight = true
+`,
+	}
+
+	dir := t.TempDir()
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("Expected no error but got %v", err)
+			}
+			if !reflect.DeepEqual(config, expected) {
+				t.Errorf("Expected %+v but got %+v", expected, config)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		err     error
+	}{
+		{name: "invalid origin", file: "cors.yaml", content: "allow_origins: https://example.com/app", err: ErrOriginPath},
+		{name: "credentials with wildcard", file: "cors.json", content: `{"allow_origins": "*", "allow_credentials": true}`, err: ErrCredentialsWithWildcard},
+		{name: "unknown response mode", file: "cors.json", content: `{"response_mode": "star"}`, err: ErrUnknownResponseMode},
+		{name: "unknown YAML key", file: "cors.yml", content: "allow_origin: https://example.com"},
+		{name: "unknown JSON key", file: "cors.json", content: `{"allow_origin": "https://example.com"}`},
+		{name: "unknown TOML key", file: "cors.toml", content: `allow_origin = "https://example.com"`},
+		{name: "wrong type", file: "cors.yaml", content: "max_age: a day"},
+		{name: "unsupported format", file: "cors.ini", content: "allow_origins=https://example.com", err: ErrUnsupportedFormat},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadConfig(path)
+			if err == nil {
+				t.Fatal("Expected an error but got nil")
+			}
+			if tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("Expected error %v but got %v", tt.err, err)
+			}
+		})
+	}
+
+	if _, err := LoadConfig(filepath.Join(dir, "missing.yaml")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist for a missing file but got %v", err)
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOW_ORIGINS", "https://console.domain.com, http://localhost:3000")
+	t.Setenv("CORS_ALLOW_ORIGIN_PATTERNS", `https://tenant-[a-z]{1,20}\.domain\.com https://[a-z]+\.example\.com`)
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "true")
+	t.Setenv("CORS_MAX_AGE", "600")
+	t.Setenv("CORS_ECHO_HEADER_ALLOWLIST", "X-Tenant-Id")
+	t.Setenv("CORS_STRICT_PREFLIGHT", "")
+	t.Setenv("OTHER_ALLOW_NULL_ORIGIN", "true")
+
+	config, err := ConfigFromEnv("CORS_")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+
+	expected := Config{
+		AllowOrigins:        "https://console.domain.com, http://localhost:3000",
+		AllowOriginPatterns: []string{`https://tenant-[a-z]{1,20}\.domain\.com`, `https://[a-z]+\.example\.com`},
+		AllowCredentials:    true,
+		MaxAge:              600,
+		EchoHeaderAllowlist: []string{"X-Tenant-Id"},
+	}
+	if !reflect.DeepEqual(config, expected) {
+		t.Errorf("Expected %+v but got %+v", expected, config)
+	}
+}
+
+func TestConfigFromEnvErrors(t *testing.T) {
+	t.Setenv("CORS_ALLOW_ORIGINS", "https://example.com")
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "yes please")
+	t.Setenv("CORS_MAX_AGE", "1d")
+	t.Setenv("CORS_RESPONSE_MODE", "star")
+
+	_, err := ConfigFromEnv("CORS_")
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a *ConfigError but got %v", err)
+	}
+
+	fields := []string{"CORS_ALLOW_CREDENTIALS", "CORS_MAX_AGE", "CORS_RESPONSE_MODE"}
+	if len(configErr.Errors) != len(fields) {
+		t.Fatalf("Expected %d problems but got %d: %v", len(fields), len(configErr.Errors), err)
+	}
+	for i, field := range fields {
+		if configErr.Errors[i].Field != field || !errors.Is(configErr.Errors[i], ErrInvalidValue) {
+			t.Errorf("Expected problem %d to be %s: %v but got %v", i, field, ErrInvalidValue, configErr.Errors[i])
+		}
+	}
+
+	t.Setenv("CORS_ALLOW_CREDENTIALS", "")
+	t.Setenv("CORS_MAX_AGE", "-1")
+	t.Setenv("CORS_RESPONSE_MODE", "")
+	if _, err := ConfigFromEnv("CORS_"); !errors.Is(err, ErrNegativeMaxAge) {
+		t.Errorf("Expected ErrNegativeMaxAge but got %v", err)
+	}
+}
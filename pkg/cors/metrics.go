@@ -0,0 +1,272 @@
+package cors
+
+import (
+	"container/heap"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Metrics receives the middleware's decision for every request with an Origin header, set
+// through Config.Metrics. Observe is called on the request path, so it must be fast and safe
+// for concurrent use, and must copy the Decision strings it keeps.
+type Metrics interface {
+	Observe(d Decision)
+}
+
+// defaultMaxOriginLabels is the number of rejected origins PrometheusMetrics labels by default
+const defaultMaxOriginLabels = 100
+
+// Labels used by PrometheusMetrics for origins that do not get a label of their own
+const (
+	otherOriginLabel   = "other"
+	invalidOriginLabel = "invalid"
+)
+
+// PrometheusMetrics is a Metrics implementation that counts requests and serves the counters
+// in the Prometheus text exposition format:
+//
+//	cors_requests_total{type="preflight|simple",result="allowed|rejected"}
+//	cors_rejected_requests_total{type="preflight|simple",reason="<Reason>"}
+//	cors_rejected_origin_requests_total{origin="<normalized origin>"}
+//
+// To bound the number of series, only the most frequently rejected origins are labeled, at
+// most maxOriginLabels of them, tracked with the space-saving algorithm: an origin seen for the
+// first time replaces the least frequent labeled one, whose count moves to origin="other".
+// Any origin accounting for more than 1/maxOriginLabels of all rejections is labeled, however
+// many other origins are seen. Malformed origins are counted as origin="invalid" and do not
+// take a label.
+type PrometheusMetrics struct {
+	maxOrigins int
+
+	// requests is indexed by preflight and allowed
+	requests [2][2]atomic.Uint64
+
+	mu       sync.Mutex
+	rejected map[rejectionKey]uint64
+	origins  map[string]*originCount
+	top      originHeap
+	other    uint64
+	invalid  uint64
+}
+
+// originCount is a labeled rejected origin
+type originCount struct {
+	origin string
+
+	// count is the number of rejections since the origin was labeled, which is exported
+	count uint64
+
+	// estimate is the space-saving upper bound of all its rejections, which ranks origins
+	estimate uint64
+
+	// index is the position in originHeap
+	index int
+}
+
+// originHeap is a min-heap of labeled origins by estimate
+type originHeap []*originCount
+
+func (h originHeap) Len() int           { return len(h) }
+func (h originHeap) Less(i, j int) bool { return h[i].estimate < h[j].estimate }
+
+func (h originHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *originHeap) Push(x any) {
+	entry := x.(*originCount)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *originHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// rejectionKey labels cors_rejected_requests_total
+type rejectionKey struct {
+	preflight bool
+	reason    Reason
+}
+
+// NewPrometheusMetrics creates a PrometheusMetrics labeling up to maxOriginLabels rejected
+// origins; zero or less uses the default of 100
+func NewPrometheusMetrics(maxOriginLabels int) *PrometheusMetrics {
+	if maxOriginLabels <= 0 {
+		maxOriginLabels = defaultMaxOriginLabels
+	}
+	return &PrometheusMetrics{
+		maxOrigins: maxOriginLabels,
+		rejected:   make(map[rejectionKey]uint64),
+		origins:    make(map[string]*originCount),
+	}
+}
+
+// Observe implements Metrics
+func (m *PrometheusMetrics) Observe(d Decision) {
+	m.requests[boolIndex(d.Preflight)][boolIndex(d.Allowed)].Add(1)
+	if d.Allowed {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected[rejectionKey{preflight: d.Preflight, reason: d.Reason}]++
+
+	origin := d.NormalizedOrigin
+	if origin != "null" && !isOrigin(origin) {
+		m.invalid++
+		return
+	}
+	if entry, ok := m.origins[origin]; ok {
+		entry.count++
+		entry.estimate++
+		heap.Fix(&m.top, entry.index)
+		return
+	}
+
+	// The origin may point into the request buffer, which is reused after the request
+	origin = strings.Clone(origin)
+	if len(m.top) < m.maxOrigins {
+		entry := &originCount{origin: origin, count: 1, estimate: 1}
+		heap.Push(&m.top, entry)
+		m.origins[origin] = entry
+		return
+	}
+	// Replace the least frequent origin, which may have been rejected up to its estimate
+	// times before it was labeled
+	entry := m.top[0]
+	delete(m.origins, entry.origin)
+	m.other += entry.count
+	entry.origin, entry.count = origin, 1
+	entry.estimate++
+	heap.Fix(&m.top, 0)
+	m.origins[origin] = entry
+}
+
+// Handler returns a Fiber handler serving the counters, e.g. app.Get("/metrics", m.Handler())
+func (m *PrometheusMetrics) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+		return c.Send(m.appendText(nil))
+	}
+}
+
+// appendText appends the counters in the Prometheus text exposition format
+func (m *PrometheusMetrics) appendText(b []byte) []byte {
+	b = append(b, "# HELP cors_requests_total CORS requests by type and result.\n"...)
+	b = append(b, "# TYPE cors_requests_total counter\n"...)
+	for _, preflight := range []bool{true, false} {
+		for _, allowed := range []bool{true, false} {
+			result := "rejected"
+			if allowed {
+				result = "allowed"
+			}
+			b = appendSample(b, "cors_requests_total",
+				m.requests[boolIndex(preflight)][boolIndex(allowed)].Load(),
+				"type", requestType(preflight), "result", result)
+		}
+	}
+
+	m.mu.Lock()
+	rejected := make([]rejectionKey, 0, len(m.rejected))
+	for key := range m.rejected {
+		rejected = append(rejected, key)
+	}
+	sort.Slice(rejected, func(i, j int) bool {
+		if rejected[i].preflight != rejected[j].preflight {
+			return rejected[i].preflight
+		}
+		return rejected[i].reason < rejected[j].reason
+	})
+	b = append(b, "# HELP cors_rejected_requests_total Rejected CORS requests by type and reason.\n"...)
+	b = append(b, "# TYPE cors_rejected_requests_total counter\n"...)
+	for _, key := range rejected {
+		b = appendSample(b, "cors_rejected_requests_total", m.rejected[key],
+			"type", requestType(key.preflight), "reason", string(key.reason))
+	}
+
+	counts := make(map[string]uint64, len(m.origins)+2)
+	for origin, entry := range m.origins {
+		counts[origin] = entry.count
+	}
+	if m.other > 0 {
+		counts[otherOriginLabel] = m.other
+	}
+	if m.invalid > 0 {
+		counts[invalidOriginLabel] = m.invalid
+	}
+	m.mu.Unlock()
+
+	origins := make([]string, 0, len(counts))
+	for origin := range counts {
+		origins = append(origins, origin)
+	}
+	sort.Strings(origins)
+	b = append(b, "# HELP cors_rejected_origin_requests_total Rejected CORS requests by origin.\n"...)
+	b = append(b, "# TYPE cors_rejected_origin_requests_total counter\n"...)
+	for _, origin := range origins {
+		b = appendSample(b, "cors_rejected_origin_requests_total", counts[origin], "origin", origin)
+	}
+
+	return b
+}
+
+// appendSample appends a sample line with label name and value pairs
+func appendSample(b []byte, name string, value uint64, labels ...string) []byte {
+	b = append(b, name...)
+	b = append(b, '{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = append(b, labels[i]...)
+		b = append(b, `="`...)
+		b = appendLabelValue(b, labels[i+1])
+		b = append(b, '"')
+	}
+	b = append(b, "} "...)
+	b = strconv.AppendUint(b, value, 10)
+	return append(b, '\n')
+}
+
+// appendLabelValue appends a label value escaped as the text format requires
+func appendLabelValue(b []byte, value string) []byte {
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\':
+			b = append(b, `\\`...)
+		case '"':
+			b = append(b, `\"`...)
+		case '\n':
+			b = append(b, `\n`...)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+func requestType(preflight bool) string {
+	if preflight {
+		return "preflight"
+	}
+	return "simple"
+}
+
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
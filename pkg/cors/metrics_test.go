@@ -0,0 +1,146 @@
+package cors
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	metrics := NewPrometheusMetrics(2)
+
+	app := fiber.New()
+	app.Get("/metrics", metrics.Handler())
+	app.Use(New(Config{
+		AllowOrigins:    "https://example.com",
+		StrictPreflight: true,
+		Metrics:         metrics,
+	}))
+	app.All("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	requests := []struct {
+		method          string
+		origin          string
+		requestedMethod string
+	}{
+		{method: "GET", origin: "https://example.com"},
+		{method: "GET", origin: "https://example.com"},
+		{method: "GET"},
+		{method: "OPTIONS", origin: "https://example.com", requestedMethod: "GET"},
+		{method: "OPTIONS", origin: "https://example.com", requestedMethod: "TRACE"},
+		{method: "GET", origin: "https://evil.com"},
+		{method: "GET", origin: "https://Evil.com:443"},
+		{method: "OPTIONS", origin: "https://a.evil.com", requestedMethod: "GET"},
+		{method: "GET", origin: "https://b.evil.com"},
+		{method: "GET", origin: "bad\"origin"},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, "/", nil)
+		if r.origin != "" {
+			req.Header.Set("Origin", r.origin)
+		}
+		if r.requestedMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", r.requestedMethod)
+		}
+		if _, err := app.Test(req); err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected the Prometheus text format but got %q", contentType)
+	}
+	body, _ := io.ReadAll(resp.Body)
+
+	expected := `# HELP cors_requests_total CORS requests by type and result.
+# TYPE cors_requests_total counter
+cors_requests_total{type="preflight",result="allowed"} 1
+cors_requests_total{type="preflight",result="rejected"} 2
+cors_requests_total{type="simple",result="allowed"} 2
+cors_requests_total{type="simple",result="rejected"} 4
+# HELP cors_rejected_requests_total Rejected CORS requests by type and reason.
+# TYPE cors_rejected_requests_total counter
+cors_rejected_requests_total{type="preflight",reason="method_not_allowed"} 1
+cors_rejected_requests_total{type="preflight",reason="origin_not_allowed"} 1
+cors_rejected_requests_total{type="simple",reason="invalid_origin"} 1
+cors_rejected_requests_total{type="simple",reason="origin_not_allowed"} 3
+# HELP cors_rejected_origin_requests_total Rejected CORS requests by origin.
+# TYPE cors_rejected_origin_requests_total counter
+cors_rejected_origin_requests_total{origin="https://b.evil.com"} 1
+cors_rejected_origin_requests_total{origin="https://evil.com"} 2
+cors_rejected_origin_requests_total{origin="invalid"} 1
+cors_rejected_origin_requests_total{origin="other"} 2
+`
+	if string(body) != expected {
+		t.Errorf("Expected metrics:\n%s\nbut got:\n%s", expected, body)
+	}
+}
+
+func TestAppendLabelValue(t *testing.T) {
+	if escaped := string(appendLabelValue(nil, "a\\b\"c\nd")); escaped != `a\\b\"c\nd` {
+		t.Errorf("Expected %q but got %q", `a\\b\"c\nd`, escaped)
+	}
+}
+
+func TestPrometheusMetricsTopOrigins(t *testing.T) {
+	metrics := NewPrometheusMetrics(3)
+	reject := func(origin string, times int) {
+		for i := 0; i < times; i++ {
+			metrics.Observe(Decision{Origin: origin, NormalizedOrigin: origin, Reason: ReasonOriginNotAllowed})
+		}
+	}
+
+	// A burst of one-off origins fills every label before the real offenders show up
+	for _, origin := range []string{"https://junk1.com", "https://junk2.com", "https://junk3.com", "https://junk4.com"} {
+		reject(origin, 1)
+	}
+	reject("https://attacker.com", 10)
+	reject("https://scanner.com", 5)
+	reject("https://junk5.com", 1)
+	reject("bad origin", 3)
+
+	text := string(metrics.appendText(nil))
+	for _, line := range []string{
+		`cors_rejected_origin_requests_total{origin="https://attacker.com"} 10`,
+		`cors_rejected_origin_requests_total{origin="https://scanner.com"} 5`,
+		`cors_rejected_origin_requests_total{origin="https://junk5.com"} 1`,
+		`cors_rejected_origin_requests_total{origin="invalid"} 3`,
+		`cors_rejected_origin_requests_total{origin="other"} 4`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected metrics to contain %q but got:\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, "junk1") || strings.Contains(text, "junk4") {
+		t.Errorf("Expected one-off origins to lose their labels but got:\n%s", text)
+	}
+}
+
+func TestPrometheusMetricsOriginLabels(t *testing.T) {
+	metrics := NewPrometheusMetrics(0)
+	metrics.Observe(Decision{Origin: "bad origin", NormalizedOrigin: "bad origin", Reason: ReasonInvalidOrigin})
+	metrics.Observe(Decision{Origin: "null", NormalizedOrigin: "null", Reason: ReasonNullOrigin})
+	metrics.Observe(Decision{Origin: "https://example.com", NormalizedOrigin: "https://example.com", Allowed: true})
+
+	text := string(metrics.appendText(nil))
+	for _, line := range []string{
+		`cors_rejected_origin_requests_total{origin="invalid"} 1`,
+		`cors_rejected_origin_requests_total{origin="null"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected metrics to contain %q but got:\n%s", line, text)
+		}
+	}
+	if strings.Contains(text, "example.com") {
+		t.Errorf("Expected allowed origins not to be labeled but got:\n%s", text)
+	}
+}
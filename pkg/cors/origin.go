@@ -0,0 +1,693 @@
+package cors
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	// maxOriginPatterns limits the number of AllowOriginPatterns entries
+	maxOriginPatterns = 100
+
+	// maxOriginPatternLength limits the length of a single AllowOriginPatterns entry
+	maxOriginPatternLength = 256
+)
+
+// Errors returned by NormalizeOrigin, created once so rejecting a malformed request origin
+// does not allocate
+var (
+	errOriginForm     = fmt.Errorf("%w: must be of the form scheme://host[:port]", ErrInvalidOrigin)
+	errOriginUserinfo = fmt.Errorf("%w: must not contain userinfo", ErrInvalidOrigin)
+	errOriginPort     = fmt.Errorf("%w: invalid port", ErrInvalidOrigin)
+	errOriginHost     = fmt.Errorf("%w: invalid host", ErrInvalidOrigin)
+)
+
+// defaultPorts maps schemes to the port implied when an origin has none
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// NormalizeOrigin returns the canonical form of an origin, used both for AllowOrigins entries
+// and for request origins: the scheme and host are lowercased, internationalized host names
+// are converted to ASCII (punycode), the port is dropped if it is the scheme's default and a
+// single trailing slash is removed, so "https://Bücher.example:443/" becomes
+// "https://xn--bcher-kva.example". Origins with a path, query or fragment are rejected with
+// ErrOriginPath, other malformed origins (including userinfo) with ErrInvalidOrigin.
+// The opaque origin "null" is returned unchanged.
+func NormalizeOrigin(origin string) (string, error) {
+	if origin == "null" {
+		return origin, nil
+	}
+
+	scheme, rest, found := strings.Cut(origin, "://")
+	if !found || !isScheme(scheme) {
+		return "", errOriginForm
+	}
+	scheme = strings.ToLower(scheme)
+
+	hostport := strings.TrimSuffix(rest, "/")
+	if strings.ContainsAny(hostport, "/?#") {
+		return "", ErrOriginPath
+	}
+	if strings.Contains(hostport, "@") {
+		return "", errOriginUserinfo
+	}
+
+	host, port := splitHostPort(hostport)
+	if port != "" {
+		if !isPort(port) {
+			return "", errOriginPort
+		}
+		port = canonicalPort(scheme, port)
+	}
+
+	host, err := asciiHost(host)
+	if err != nil || !isHost(host) {
+		return "", errOriginHost
+	}
+
+	// Most origins are already canonical; return them as is rather than building a copy
+	if origin[:len(scheme)] == scheme && equalHostPort(rest, host, port) {
+		return origin, nil
+	}
+	if port == "" {
+		return scheme + "://" + host, nil
+	}
+	return scheme + "://" + host + ":" + port, nil
+}
+
+// equalHostPort reports whether s is host, or host:port when port is not empty
+func equalHostPort(s, host, port string) bool {
+	if port == "" {
+		return s == host
+	}
+	return len(s) == len(host)+1+len(port) && s[:len(host)] == host && s[len(host)] == ':' && s[len(host)+1:] == port
+}
+
+// canonicalPort strips leading zeros from a valid port and returns "" for the scheme's default
+func canonicalPort(scheme, port string) string {
+	port = strings.TrimLeft(port, "0")
+	if port == defaultPorts[scheme] {
+		return ""
+	}
+	return port
+}
+
+// asciiHost lowercases a host and converts internationalized domain names to ASCII
+func asciiHost(host string) (string, error) {
+	for i := 0; i < len(host); i++ {
+		if host[i] >= 0x80 {
+			return idna.Lookup.ToASCII(host)
+		}
+	}
+	return strings.ToLower(host), nil
+}
+
+// originPattern is a wildcard entry from AllowOrigins such as
+// "https://*.domain.com" or "http://localhost:*"
+type originPattern struct {
+	// raw is the pattern as written in the configuration
+	raw string
+
+	scheme string
+
+	// host is the literal host, or the required suffix (including the leading dot)
+	// when subdomains is true
+	host       string
+	subdomains bool
+
+	// port is empty for no explicit port, "*" for any port, or a literal port number
+	port string
+}
+
+// originSet holds the normalized exact origins and wildcard patterns from an origin list
+type originSet struct {
+	exact    map[string]bool
+	patterns []originPattern
+}
+
+// add parses an origin list entry into the set, returning ErrDuplicate if it is already present
+func (s *originSet) add(entry string) error {
+	// Wildcard entries are matched by pattern rather than by map lookup
+	if strings.Contains(entry, "*") {
+		pattern, err := parseOriginPattern(entry)
+		if err != nil {
+			return err
+		}
+		for _, existing := range s.patterns {
+			if strings.EqualFold(existing.raw, entry) {
+				return ErrDuplicate
+			}
+		}
+		s.patterns = append(s.patterns, pattern)
+		return nil
+	}
+
+	normalized, err := NormalizeOrigin(entry)
+	if err != nil {
+		return err
+	}
+	if s.exact[normalized] {
+		return ErrDuplicate
+	}
+	if s.exact == nil {
+		s.exact = make(map[string]bool)
+	}
+	s.exact[normalized] = true
+	return nil
+}
+
+// empty reports whether the set has no entries
+func (s *originSet) empty() bool {
+	return len(s.exact) == 0 && len(s.patterns) == 0
+}
+
+// match reports whether a normalized origin is in the set
+func (s *originSet) match(normalized string) bool {
+	_, ok := s.matchEntry(normalized)
+	return ok
+}
+
+// matchEntry is like match but also returns the entry that matched, as normalized for exact
+// entries and as written for wildcard entries
+func (s *originSet) matchEntry(normalized string) (string, bool) {
+	if s.exact[normalized] {
+		return normalized, true
+	}
+	return matchOriginPatterns(s.patterns, normalized)
+}
+
+// matchOriginPatterns returns the first of the wildcard patterns matching a normalized origin
+func matchOriginPatterns(patterns []originPattern, origin string) (string, bool) {
+	if len(patterns) == 0 {
+		return "", false
+	}
+	scheme, host, port, ok := splitOrigin(origin)
+	if !ok {
+		return "", false
+	}
+	for _, pattern := range patterns {
+		if pattern.match(scheme, host, port) {
+			return pattern.raw, true
+		}
+	}
+	return "", false
+}
+
+// compileOriginRegexp compiles an AllowOriginPatterns entry, anchored to match the whole
+// normalized origin. Patterns that could match arbitrary hosts are rejected: "." outside a
+// character class (use "\." or a class such as "[a-z0-9-]" instead) and patterns whose host
+// does not end in a literal domain of at least two labels such as `\.domain\.com`, is not
+// entirely literal, and is not an IPv4 address with at least its first two octets literal.
+func compileOriginRegexp(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxOriginPatternLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrInvalidOriginPattern, maxOriginPatternLength)
+	}
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOriginPattern, err)
+	}
+	if matchesAnyChar(parsed) {
+		return nil, fmt.Errorf("%w: use \\. or a character class instead of .", ErrOriginPatternTooBroad)
+	}
+	if !restrictsHost(parsed.Simplify()) {
+		return nil, fmt.Errorf("%w: the host must end in a literal domain such as \\.domain\\.com", ErrOriginPatternTooBroad)
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOriginPattern, err)
+	}
+	return re, nil
+}
+
+// matchesAnyChar reports whether a parsed regular expression contains "." or an equivalent
+func matchesAnyChar(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpAnyChar || re.Op == syntax.OpAnyCharNotNL {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if matchesAnyChar(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxLiteralSuffixes limits the alternatives restrictsHost follows through a pattern
+const maxLiteralSuffixes = 64
+
+// literalSuffix is text every match of a pattern ends with; complete is set when the pattern
+// matches exactly that text
+type literalSuffix struct {
+	text     string
+	complete bool
+}
+
+// restrictsHost reports whether every origin matched by a simplified pattern has a host that
+// is literal, ends in a literal domain of at least two labels or is an IPv4 address with at
+// least its first two octets literal
+func restrictsHost(re *syntax.Regexp) bool {
+	items := patternItems(re)
+	if len(items) == 1 && items[0].Op == syntax.OpAlternate {
+		for _, sub := range items[0].Sub {
+			if !restrictsHost(sub) {
+				return false
+			}
+		}
+		return true
+	}
+
+	items = trimPort(items)
+	if isIPv4Pattern(items) {
+		return true
+	}
+	suffixes, ok := concatSuffixes(items)
+	if !ok {
+		return false
+	}
+	for _, suffix := range suffixes {
+		if _, host, found := strings.Cut(suffix.text, "://"); found && host != "" {
+			continue
+		}
+		host := suffix.text
+		if !strings.HasPrefix(host, ".") || !strings.Contains(host[1:], ".") || !isHostLabels(host[1:]) {
+			return false
+		}
+	}
+	return true
+}
+
+// patternItems returns the sequence of expressions a pattern is made of, without captures
+// and leading or trailing anchors
+func patternItems(re *syntax.Regexp) []*syntax.Regexp {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	items := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		items = re.Sub
+	}
+	for len(items) > 0 && isZeroWidth(items[0]) {
+		items = items[1:]
+	}
+	for len(items) > 0 && isZeroWidth(items[len(items)-1]) {
+		items = items[:len(items)-1]
+	}
+	if len(items) == 1 && items[0] != re {
+		return patternItems(items[0])
+	}
+	return items
+}
+
+// trimPort removes a trailing port such as ":8080", ":[0-9]+" or "(:[0-9]+)?" from the
+// items of a pattern
+func trimPort(items []*syntax.Regexp) []*syntax.Regexp {
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		if item.Op == syntax.OpLiteral {
+			// The port may start within a literal, as in `\.com:8080` or `\.com:[0-9]+`
+			j := len(item.Rune) - 1
+			for j >= 0 && item.Rune[j] >= '0' && item.Rune[j] <= '9' {
+				j--
+			}
+			if j < 0 || item.Rune[j] != ':' {
+				return items
+			}
+			trimmed := *item
+			trimmed.Rune = item.Rune[:j]
+			return append(items[:i:i], &trimmed)
+		}
+		if !matchesOnly(item, "0123456789:") {
+			return items
+		}
+		if startsWithColon(item) {
+			// An optional colon followed by digits would let the digits extend the host
+			if i < len(items)-1 && canBeEmpty(item) {
+				return items
+			}
+			return items[:i]
+		}
+	}
+	return items
+}
+
+// isIPv4Pattern reports whether items only match IPv4 hosts whose first two octets are
+// literal, such as `http://10\.0\.0\.[0-9]{1,3}`
+func isIPv4Pattern(items []*syntax.Regexp) bool {
+	prefix := ""
+	for len(items) > 0 && items[0].Op == syntax.OpLiteral {
+		prefix += string(items[0].Rune)
+		items = items[1:]
+	}
+	_, host, found := strings.Cut(prefix, "://")
+	if !found || strings.Count(host, ".") < 2 || strings.Trim(host, "0123456789.") != "" {
+		return false
+	}
+	for _, item := range items {
+		if !matchesOnly(item, "0123456789.") {
+			return false
+		}
+	}
+	return true
+}
+
+// concatSuffixes returns the literal suffixes of a sequence of expressions
+func concatSuffixes(items []*syntax.Regexp) ([]literalSuffix, bool) {
+	suffixes := []literalSuffix{{complete: true}}
+	for i := len(items) - 1; i >= 0; i-- {
+		var next []literalSuffix
+		var itemSuffixes []literalSuffix
+		for _, suffix := range suffixes {
+			if !suffix.complete {
+				next = append(next, suffix)
+				continue
+			}
+			if itemSuffixes == nil {
+				var ok bool
+				if itemSuffixes, ok = literalSuffixes(items[i]); !ok {
+					return nil, false
+				}
+			}
+			for _, s := range itemSuffixes {
+				next = append(next, literalSuffix{text: s.text + suffix.text, complete: s.complete})
+			}
+		}
+		if len(next) > maxLiteralSuffixes {
+			return nil, false
+		}
+		suffixes = next
+	}
+	return suffixes, true
+}
+
+// literalSuffixes returns the literal suffixes of the alternatives of a simplified pattern
+func literalSuffixes(re *syntax.Regexp) ([]literalSuffix, bool) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		text := string(re.Rune)
+		if re.Flags&syntax.FoldCase != 0 {
+			text = strings.ToLower(text)
+		}
+		return []literalSuffix{{text: text, complete: true}}, true
+	case syntax.OpCharClass:
+		if len(re.Rune) == 2 && re.Rune[0] == re.Rune[1] {
+			return []literalSuffix{{text: string(re.Rune[0]), complete: true}}, true
+		}
+	case syntax.OpCapture:
+		return literalSuffixes(re.Sub[0])
+	case syntax.OpConcat:
+		return concatSuffixes(re.Sub)
+	case syntax.OpAlternate:
+		var suffixes []literalSuffix
+		for _, sub := range re.Sub {
+			subSuffixes, ok := literalSuffixes(sub)
+			if !ok || len(suffixes)+len(subSuffixes) > maxLiteralSuffixes {
+				return nil, false
+			}
+			suffixes = append(suffixes, subSuffixes...)
+		}
+		return suffixes, true
+	case syntax.OpQuest:
+		suffixes, ok := literalSuffixes(re.Sub[0])
+		return append(suffixes, literalSuffix{complete: true}), ok
+	case syntax.OpPlus:
+		// Every match ends with a match of the repeated expression
+		suffixes, ok := literalSuffixes(re.Sub[0])
+		for i := range suffixes {
+			suffixes[i].complete = false
+		}
+		return suffixes, ok
+	}
+	if isZeroWidth(re) {
+		return []literalSuffix{{complete: true}}, true
+	}
+	return []literalSuffix{{}}, true
+}
+
+// isZeroWidth reports whether re only matches the empty string
+func isZeroWidth(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText,
+		syntax.OpEndText, syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return true
+	}
+	return false
+}
+
+// canBeEmpty reports whether a simplified pattern can match the empty string
+func canBeEmpty(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpQuest, syntax.OpStar:
+		return true
+	case syntax.OpCapture, syntax.OpPlus:
+		return canBeEmpty(re.Sub[0])
+	}
+	return isZeroWidth(re)
+}
+
+// startsWithColon reports whether every non-empty match of a pattern starts with ':'
+func startsWithColon(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return len(re.Rune) > 0 && re.Rune[0] == ':'
+	case syntax.OpCapture, syntax.OpQuest, syntax.OpStar, syntax.OpPlus:
+		return startsWithColon(re.Sub[0])
+	case syntax.OpConcat:
+		return len(re.Sub) > 0 && startsWithColon(re.Sub[0])
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !startsWithColon(sub) {
+				return false
+			}
+		}
+		return len(re.Sub) > 0
+	}
+	return false
+}
+
+// matchesOnly reports whether a simplified pattern only matches characters in chars
+func matchesOnly(re *syntax.Regexp, chars string) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if !strings.ContainsRune(chars, r) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpCharClass:
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if !strings.ContainsRune(chars, r) {
+					return false
+				}
+			}
+		}
+		return true
+	case syntax.OpCapture, syntax.OpConcat, syntax.OpAlternate, syntax.OpQuest, syntax.OpStar, syntax.OpPlus:
+		for _, sub := range re.Sub {
+			if !matchesOnly(sub, chars) {
+				return false
+			}
+		}
+		return true
+	}
+	return isZeroWidth(re)
+}
+
+// parseOriginPattern parses a wildcard origin entry. A "*" may only appear as the
+// leftmost host label ("*.domain.com", matching one or more subdomain labels) or
+// as the whole port (":*", matching any port or none).
+func parseOriginPattern(pattern string) (originPattern, error) {
+	p := originPattern{raw: pattern}
+
+	scheme, rest, found := strings.Cut(pattern, "://")
+	if !found || !isScheme(scheme) {
+		return p, fmt.Errorf("%w: must start with a scheme such as https://", ErrInvalidOrigin)
+	}
+	p.scheme = strings.ToLower(scheme)
+
+	if strings.ContainsAny(rest, "/?#") {
+		return p, ErrOriginPath
+	}
+	if strings.Contains(rest, "@") {
+		return p, fmt.Errorf("%w: must not contain userinfo", ErrInvalidOrigin)
+	}
+
+	host, port := splitHostPort(rest)
+	if port == "*" {
+		p.port = port
+	} else if port != "" {
+		if !isPort(port) {
+			return p, fmt.Errorf("%w: invalid port", ErrInvalidOrigin)
+		}
+		p.port = canonicalPort(p.scheme, port)
+	}
+
+	if strings.HasPrefix(host, "*.") {
+		p.subdomains = true
+		host = host[1:]
+	}
+	if strings.Contains(host, "*") {
+		return p, fmt.Errorf("%w: * may only be used as the leftmost host label or as the port", ErrInvalidOrigin)
+	}
+	// Convert to the same form NormalizeOrigin gives request origins
+	host, err := asciiHost(strings.TrimPrefix(host, "."))
+	if err != nil {
+		return p, fmt.Errorf("%w: invalid host", ErrInvalidOrigin)
+	}
+	if p.subdomains {
+		// Require at least two labels after the wildcard so "*.com" can't allow a whole TLD
+		if !strings.Contains(host, ".") || !isHostLabels(host) {
+			return p, fmt.Errorf("%w: *. must be followed by a domain with at least two labels", ErrInvalidOrigin)
+		}
+		host = "." + host
+	} else if !isHost(host) {
+		return p, fmt.Errorf("%w: invalid host", ErrInvalidOrigin)
+	}
+	p.host = host
+
+	if !p.subdomains && p.port != "*" {
+		return p, fmt.Errorf("%w: * may only be used as the leftmost host label or as the port", ErrInvalidOrigin)
+	}
+
+	return p, nil
+}
+
+// match reports whether an origin split by splitOrigin satisfies the pattern
+func (p originPattern) match(scheme, host, port string) bool {
+	if scheme != p.scheme {
+		return false
+	}
+	if p.port != "*" && port != p.port {
+		return false
+	}
+	if !p.subdomains {
+		return host == p.host
+	}
+	// The host must end on a label boundary and have at least one non-empty label before it,
+	// so "evil-domain.com" and ".domain.com" never match "*.domain.com"
+	if len(host) <= len(p.host) || !strings.HasSuffix(host, p.host) {
+		return false
+	}
+	return isHostLabels(host[:len(host)-len(p.host)])
+}
+
+// normalizeRequestOrigin normalizes a request Origin header with NormalizeOrigin, falling back
+// to lowercasing values that are not valid origins so they can never match a normalized entry
+// by accident
+func normalizeRequestOrigin(origin string) string {
+	if normalized, err := NormalizeOrigin(origin); err == nil {
+		return normalized
+	}
+	return strings.ToLower(origin)
+}
+
+// isOrigin reports whether s is a serialized origin of the form scheme://host[:port]
+func isOrigin(s string) bool {
+	_, _, _, ok := splitOrigin(s)
+	return ok
+}
+
+// splitOrigin splits a serialized origin ("scheme://host[:port]") into its lowercase
+// scheme and host and its port. ok is false when origin is not of that form.
+func splitOrigin(origin string) (scheme, host, port string, ok bool) {
+	scheme, rest, found := strings.Cut(origin, "://")
+	if !found || !isScheme(scheme) || rest == "" || strings.ContainsAny(rest, "/?#@") {
+		return "", "", "", false
+	}
+	host, port = splitHostPort(rest)
+	if host == "" || (port != "" && !isPort(port)) {
+		return "", "", "", false
+	}
+	return strings.ToLower(scheme), strings.ToLower(host), port, true
+}
+
+// splitHostPort separates an optional trailing ":port" from a host, leaving
+// bracketed IPv6 literals intact
+func splitHostPort(hostport string) (host, port string) {
+	i := strings.LastIndexByte(hostport, ':')
+	if i < 0 || strings.IndexByte(hostport[i:], ']') >= 0 {
+		return hostport, ""
+	}
+	return hostport[:i], hostport[i+1:]
+}
+
+// isScheme reports whether s is a valid URL scheme per RFC 3986
+func isScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case i > 0 && (c >= '0' && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isPort reports whether s is a decimal port number between 1 and 65535
+func isPort(s string) bool {
+	if s == "" || len(s) > 5 {
+		return false
+	}
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n >= 1 && n <= 65535
+}
+
+// isHost reports whether host is a DNS name, an IPv4 address or a bracketed IPv6 literal
+func isHost(host string) bool {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		inner := host[1 : len(host)-1]
+		if !strings.Contains(inner, ":") {
+			return false
+		}
+		for i := 0; i < len(inner); i++ {
+			c := inner[i]
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F' || c == ':' || c == '.') {
+				return false
+			}
+		}
+		return true
+	}
+	return isHostLabels(host)
+}
+
+// isHostLabels reports whether s is a dot-separated list of non-empty DNS labels.
+// Labels are matched case-insensitively and may contain letters, digits, '-' and '_'.
+func isHostLabels(s string) bool {
+	if s == "" {
+		return false
+	}
+	for more := true; more; {
+		var label string
+		label, s, more = strings.Cut(s, ".")
+		if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,247 @@
+package cors
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Policy applies a Config to the requests matched by its route name, path prefix and methods
+// A request must satisfy every criterion that is set; at least one criterion is required
+type Policy struct {
+	// Name matches requests served by the Fiber routes registered with this name
+	// Routes are looked up on the first request, so they must be registered before serving,
+	// and are matched with Fiber's route syntax and the app's CaseSensitive and StrictRouting
+	Name string
+
+	// Prefix matches request paths equal to or below this prefix, e.g. "/api/public", compared
+	// with the app's CaseSensitive and StrictRouting settings like Fiber routes
+	Prefix string
+
+	// Methods matches requests using one of these methods
+	// For preflight requests the Access-Control-Request-Method is used instead of OPTIONS
+	Methods []string
+
+	// Config is the CORS configuration applied to matching requests
+	Config Config
+}
+
+// Policies selects a CORS configuration per request from a set of rules
+//
+// Rules are tried in order of precedence and the first match wins:
+//  1. rules with a route Name
+//  2. rules with a Prefix, longest prefix first; for equal prefixes, rules that also set Methods first
+//  3. rules with only Methods
+//
+// Rules of equal precedence are tried in the order they are declared. Requests matching
+// no rule use Fallback, or are passed to the next handler without CORS processing if
+// Fallback is nil.
+type Policies struct {
+	Rules    []Policy
+	Fallback *Config
+}
+
+// NewPolicies creates a CORS middleware handler that applies a different Config per route
+// It panics if any configuration is invalid; use NewPoliciesWithError to handle the error instead
+func NewPolicies(policies Policies) fiber.Handler {
+	handler, err := NewPoliciesWithError(policies)
+	if err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+// NewPoliciesWithError creates a CORS middleware handler that applies a different Config per route
+// If any configuration is invalid it returns a *ConfigError listing every problem found,
+// with field names prefixed by the rule they belong to, e.g. "Rules[1].AllowOrigins"
+func NewPoliciesWithError(policies Policies) (fiber.Handler, error) {
+	r, err := compileRouter(policies)
+	if err != nil {
+		return nil, err
+	}
+	return r.handle, nil
+}
+
+// router is the compiled form of Policies
+type router struct {
+	rules    []*rule
+	fallback *policy
+
+	// routesOnce resolves rule names to route paths on the first request
+	routesOnce sync.Once
+
+	// config is the app's configuration, used to match route paths as Fiber does
+	config fiber.Config
+}
+
+// rule is a compiled Policy
+type rule struct {
+	name    string
+	prefix  string
+	methods map[string]bool
+	policy  *policy
+
+	// index is the declaration order, used to break precedence ties
+	index int
+
+	// routes maps methods to the paths of the routes registered under name
+	routes map[string][]string
+}
+
+func compileRouter(policies Policies) (*router, error) {
+	r := &router{}
+	errs := &ConfigError{}
+
+	for i, rp := range policies.Rules {
+		field := "Rules[" + strconv.Itoa(i) + "]"
+		if rp.Name == "" && rp.Prefix == "" && len(rp.Methods) == 0 {
+			errs.add(field, "", ErrEmptyPolicy)
+			continue
+		}
+
+		ru := &rule{name: rp.Name, prefix: rp.Prefix, index: i}
+		if rp.Prefix != "" && !strings.HasPrefix(rp.Prefix, "/") {
+			errs.add(field+".Prefix", rp.Prefix, ErrInvalidPathPattern)
+		}
+		if rp.Prefix != "/" {
+			ru.prefix = strings.TrimSuffix(rp.Prefix, "/")
+		}
+		if len(rp.Methods) > 0 {
+			ru.methods = make(map[string]bool, len(rp.Methods))
+			for _, method := range rp.Methods {
+				method = strings.ToUpper(method)
+				if !knownMethods[method] {
+					errs.add(field+".Methods", method, ErrUnknownMethod)
+				}
+				ru.methods[method] = true
+			}
+		}
+
+		p, err := compile(rp.Config)
+		if err != nil {
+			errs.addPrefixed(field+".", err)
+			continue
+		}
+		ru.policy = p
+		r.rules = append(r.rules, ru)
+	}
+
+	if policies.Fallback != nil {
+		p, err := compile(*policies.Fallback)
+		if err != nil {
+			errs.addPrefixed("Fallback.", err)
+		}
+		r.fallback = p
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(r.rules, func(i, j int) bool {
+		a, b := r.rules[i], r.rules[j]
+		if (a.name != "") != (b.name != "") {
+			return a.name != ""
+		}
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) > len(b.prefix)
+		}
+		if (a.methods != nil) != (b.methods != nil) {
+			return a.methods != nil
+		}
+		return a.index < b.index
+	})
+
+	return r, nil
+}
+
+// handle is the middleware handler for the router
+func (r *router) handle(c *fiber.Ctx) error {
+	r.routesOnce.Do(func() { r.resolveRoutes(c.App()) })
+
+	method := c.Method()
+	if method == fiber.MethodOptions {
+		if requested := c.Get("Access-Control-Request-Method"); requested != "" {
+			method = requested
+		}
+	}
+	requestPath := c.Path()
+
+	for _, ru := range r.rules {
+		if ru.match(r.config, method, requestPath) {
+			return ru.policy.handle(c)
+		}
+	}
+	if r.fallback != nil {
+		return r.fallback.handle(c)
+	}
+	return c.Next()
+}
+
+// resolveRoutes looks up the routes registered under each rule name
+func (r *router) resolveRoutes(app *fiber.App) {
+	r.config = app.Config()
+	routes := app.GetRoutes(true)
+	for _, ru := range r.rules {
+		if ru.name == "" {
+			continue
+		}
+		ru.routes = make(map[string][]string)
+		for _, route := range routes {
+			if route.Name == ru.name {
+				ru.routes[route.Method] = append(ru.routes[route.Method], route.Path)
+			}
+		}
+	}
+}
+
+// match reports whether a request satisfies every criterion of the rule
+func (ru *rule) match(config fiber.Config, method, requestPath string) bool {
+	if ru.methods != nil && !ru.methods[method] {
+		return false
+	}
+	if ru.prefix != "" && ru.prefix != "/" && !hasPathPrefix(config, requestPath, ru.prefix) {
+		return false
+	}
+	if ru.name != "" {
+		for _, routePath := range ru.routes[method] {
+			if matchRoutePath(config, routePath, requestPath) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// hasPathPrefix reports whether a request path is equal to or below prefix, comparing them
+// with the app's CaseSensitive and StrictRouting settings as Fiber's router does
+func hasPathPrefix(config fiber.Config, requestPath, prefix string) bool {
+	if !config.StrictRouting && len(requestPath) > 1 {
+		requestPath = strings.TrimRight(requestPath, "/")
+	}
+	if len(requestPath) < len(prefix) || len(requestPath) > len(prefix) && requestPath[len(prefix)] != '/' {
+		return false
+	}
+	if config.CaseSensitive {
+		return requestPath[:len(prefix)] == prefix
+	}
+	return strings.EqualFold(requestPath[:len(prefix)], prefix)
+}
+
+// matchRoutePath reports whether a request path is served by a Fiber route path
+// Matching is done by Fiber itself, so every route syntax and the app's CaseSensitive and
+// StrictRouting settings are honored
+func matchRoutePath(config fiber.Config, routePath, requestPath string) bool {
+	// Fiber ignores trailing slashes when routing unless StrictRouting is set
+	if !config.StrictRouting && len(requestPath) > 1 {
+		requestPath = strings.TrimRight(requestPath, "/")
+		if requestPath == "" {
+			requestPath = "/"
+		}
+	}
+	return fiber.RoutePatternMatch(requestPath, routePath, config)
+}
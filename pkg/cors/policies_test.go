@@ -0,0 +1,262 @@
+package cors
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPolicies(t *testing.T) {
+	public := Config{AllowOrigins: "*", AllowMethods: "GET, HEAD"}
+	console := Config{AllowOrigins: "https://console.domain.com", AllowCredentials: true, AllowMethods: "GET, POST, PUT, DELETE"}
+	uploads := Config{AllowOrigins: "https://uploads.domain.com", AllowMethods: "PUT"}
+	webhook := Config{AllowOrigins: "https://hooks.partner.com", AllowMethods: "POST"}
+	fallback := Config{AllowOrigins: "https://www.domain.com"}
+
+	handler := NewPolicies(Policies{
+		Rules: []Policy{
+			{Prefix: "/api", Config: console},
+			{Prefix: "/api/public", Config: public},
+			{Prefix: "/api", Methods: []string{"PUT"}, Config: uploads},
+			{Name: "partner-webhook", Config: webhook},
+			{Methods: []string{"DELETE"}, Config: console},
+		},
+		Fallback: &fallback,
+	})
+
+	app := fiber.New()
+	app.Use(handler)
+	app.Post("/api/hooks/:partner", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	}).Name("partner-webhook")
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		origin         string
+		preflightFor   string
+		expectedOrigin string
+	}{
+		{name: "public API allows any origin", method: "GET", path: "/api/public/items", origin: "https://anyone.com", expectedOrigin: "https://anyone.com"},
+		{name: "longest prefix wins", method: "GET", path: "/api/public", origin: "https://console.domain.com", expectedOrigin: "https://console.domain.com"},
+		{name: "console API rejects other origins", method: "GET", path: "/api/users", origin: "https://anyone.com"},
+		{name: "console API allows console", method: "GET", path: "/api/users", origin: "https://console.domain.com", expectedOrigin: "https://console.domain.com"},
+		{name: "prefix with methods beats prefix alone", method: "PUT", path: "/api/files", origin: "https://uploads.domain.com", expectedOrigin: "https://uploads.domain.com"},
+		{name: "preflight uses requested method", method: "OPTIONS", preflightFor: "PUT", path: "/api/files", origin: "https://uploads.domain.com", expectedOrigin: "https://uploads.domain.com"},
+		{name: "prefix ignores case like the router", method: "GET", path: "/API/users", origin: "https://console.domain.com", expectedOrigin: "https://console.domain.com"},
+		{name: "prefix ignores trailing slash like the router", method: "GET", path: "/api/public/", origin: "https://anyone.com", expectedOrigin: "https://anyone.com"},
+		{name: "prefix with trailing slash below prefix", method: "GET", path: "/api/users/", origin: "https://console.domain.com", expectedOrigin: "https://console.domain.com"},
+		{name: "prefix matches on segment boundary", method: "GET", path: "/apiary", origin: "https://www.domain.com", expectedOrigin: "https://www.domain.com"},
+		{name: "route name beats prefix", method: "POST", path: "/api/hooks/stripe", origin: "https://hooks.partner.com", expectedOrigin: "https://hooks.partner.com"},
+		{name: "route name needs matching method", method: "GET", path: "/api/hooks/stripe", origin: "https://hooks.partner.com"},
+		{name: "method-only rule", method: "DELETE", path: "/other", origin: "https://console.domain.com", expectedOrigin: "https://console.domain.com"},
+		{name: "fallback", method: "GET", path: "/", origin: "https://www.domain.com", expectedOrigin: "https://www.domain.com"},
+		{name: "fallback rejects", method: "GET", path: "/", origin: "https://console.domain.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflightFor != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.preflightFor)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			origin := resp.Header.Get("Access-Control-Allow-Origin")
+			if origin != tt.expectedOrigin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.expectedOrigin, origin)
+			}
+		})
+	}
+}
+
+func TestPoliciesWithoutFallback(t *testing.T) {
+	app := fiber.New()
+	app.Use(NewPolicies(Policies{
+		Rules: []Policy{{Prefix: "/api", Config: Config{AllowOrigins: "https://example.com"}}},
+	}))
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("OPTIONS", "/other", nil)
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+
+	// Unmatched requests bypass CORS entirely and reach the route
+	if resp.StatusCode != 200 {
+		t.Errorf("Expected status 200 but got %d", resp.StatusCode)
+	}
+	if vary := resp.Header.Get("Vary"); vary != "" {
+		t.Errorf("Expected no Vary header but got %q", vary)
+	}
+}
+
+func TestPoliciesPrefixStrictRouting(t *testing.T) {
+	app := fiber.New(fiber.Config{CaseSensitive: true, StrictRouting: true})
+	app.Use(NewPolicies(Policies{
+		Rules: []Policy{
+			{Prefix: "/api/public", Config: Config{AllowOrigins: "*"}},
+			{Methods: []string{"delete"}, Config: Config{AllowOrigins: "https://console.domain.com"}},
+		},
+		Fallback: &Config{AllowOrigins: "https://www.domain.com"},
+	}))
+	app.All("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		method         string
+		path           string
+		origin         string
+		expectedOrigin string
+	}{
+		{"GET", "/api/public", "https://anyone.com", "https://anyone.com"},
+		{"GET", "/api/public/items", "https://anyone.com", "https://anyone.com"},
+		{"GET", "/API/public", "https://anyone.com", ""},
+		{"GET", "/api/public/", "https://anyone.com", "https://anyone.com"},
+		{"DELETE", "/other", "https://console.domain.com", "https://console.domain.com"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Header.Set("Origin", tt.origin)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != tt.expectedOrigin {
+			t.Errorf("Expected Access-Control-Allow-Origin for %s %s to be %q but got %q", tt.method, tt.path, tt.expectedOrigin, origin)
+		}
+	}
+}
+
+func TestPoliciesRouteNameCaseSensitive(t *testing.T) {
+	app := fiber.New(fiber.Config{CaseSensitive: true})
+	app.Use(NewPolicies(Policies{
+		Rules:    []Policy{{Name: "files", Config: Config{AllowOrigins: "https://files.domain.com"}}},
+		Fallback: &Config{AllowOrigins: "https://www.domain.com"},
+	}))
+	app.Get("/files/:name.:ext", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	}).Name("files")
+	app.Get("/*", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		path           string
+		expectedOrigin string
+	}{
+		{"/files/report.pdf", "https://files.domain.com"},
+		{"/Files/report.pdf", ""},
+		{"/files/report", ""},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		req.Header.Set("Origin", "https://files.domain.com")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if origin := resp.Header.Get("Access-Control-Allow-Origin"); origin != tt.expectedOrigin {
+			t.Errorf("Expected Access-Control-Allow-Origin for %s to be %q but got %q", tt.path, tt.expectedOrigin, origin)
+		}
+	}
+}
+
+func TestNewPoliciesWithError(t *testing.T) {
+	_, err := NewPoliciesWithError(Policies{
+		Rules: []Policy{
+			{Prefix: "/api", Config: Config{AllowOrigins: "https://example.com/app"}},
+			{Config: Config{}},
+			{Prefix: "api", Methods: []string{"FETCH"}},
+		},
+		Fallback: &Config{MaxAge: -1},
+	})
+
+	var configErr *ConfigError
+	if !errors.As(err, &configErr) {
+		t.Fatalf("Expected a *ConfigError but got %v", err)
+	}
+
+	expected := []struct {
+		field string
+		err   error
+	}{
+		{"Rules[0].AllowOrigins", ErrOriginPath},
+		{"Rules[1]", ErrEmptyPolicy},
+		{"Rules[2].Prefix", ErrInvalidPathPattern},
+		{"Rules[2].Methods", ErrUnknownMethod},
+		{"Fallback.MaxAge", ErrNegativeMaxAge},
+	}
+	if len(configErr.Errors) != len(expected) {
+		t.Fatalf("Expected %d problems but got %d: %v", len(expected), len(configErr.Errors), err)
+	}
+	for i, e := range expected {
+		if configErr.Errors[i].Field != e.field || !errors.Is(configErr.Errors[i], e.err) {
+			t.Errorf("Expected problem %d to be %s: %v but got %v", i, e.field, e.err, configErr.Errors[i])
+		}
+	}
+}
+
+func TestMatchRoutePath(t *testing.T) {
+	strict := fiber.Config{CaseSensitive: true, StrictRouting: true}
+
+	tests := []struct {
+		route   string
+		path    string
+		config  fiber.Config
+		matched bool
+	}{
+		{route: "/", path: "/", matched: true},
+		{route: "/", path: "/users", matched: false},
+		{route: "/users", path: "/users", matched: true},
+		{route: "/users", path: "/Users/", matched: true},
+		{route: "/users", path: "/users/1", matched: false},
+		{route: "/users", path: "/Users", config: strict, matched: false},
+		{route: "/users", path: "/users/", config: strict, matched: false},
+		{route: "/users/", path: "/users/", config: strict, matched: true},
+		{route: "/users/:id", path: "/users/1", matched: true},
+		{route: "/users/:id", path: "/users", matched: false},
+		{route: "/users/:id?", path: "/users", matched: true},
+		{route: "/users/:id?", path: "/users/1", matched: true},
+		{route: "/users/:id<int>", path: "/users/1", matched: true},
+		{route: "/users/:id<int>", path: "/users/me", matched: false},
+		{route: "/files/*", path: "/files", matched: true},
+		{route: "/files/*", path: "/files/a/b", matched: true},
+		{route: "/files/+", path: "/files", matched: false},
+		{route: "/files/+", path: "/files/a/b", matched: true},
+		{route: "/api/v1*", path: "/api/v1", matched: true},
+		{route: "/api/v1*", path: "/api/v1beta/items", matched: true},
+		{route: "/api/v1*", path: "/api/v2", matched: false},
+		{route: "/api/v1+", path: "/api/v1", matched: false},
+		{route: "/flights/:from-:to", path: "/flights/LAX-SFO", matched: true},
+		{route: "/flights/:from-:to", path: "/flights/LAX", matched: false},
+		{route: "/files/:name.:ext", path: "/files/report.pdf", matched: true},
+		{route: "/files/:name.:ext", path: "/files/report", matched: false},
+	}
+
+	for _, tt := range tests {
+		if matched := matchRoutePath(tt.config, tt.route, tt.path); matched != tt.matched {
+			t.Errorf("matchRoutePath(%q, %q) = %v, expected %v", tt.route, tt.path, matched, tt.matched)
+		}
+	}
+}
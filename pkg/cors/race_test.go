@@ -0,0 +1,6 @@
+//go:build race
+
+package cors
+
+// raceEnabled reports whether the race detector is on, which adds allocations of its own
+const raceEnabled = true
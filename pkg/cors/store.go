@@ -0,0 +1,240 @@
+package cors
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// OriginStore decides whether origins are allowed from an external source such as a
+// database of tenant domains. It is consulted by the middleware through Config.OriginStore
+// with origins normalized by NormalizeOrigin, and must be safe for concurrent use.
+type OriginStore interface {
+	Allowed(ctx context.Context, origin string) (bool, error)
+}
+
+// CacheConfig configures NewCachedStore
+type CacheConfig struct {
+	// TTL is how long an allowed origin is cached
+	// Default is one minute
+	TTL time.Duration
+
+	// NegativeTTL is how long a rejected origin is cached, so unknown origins do not reach
+	// the store on every request
+	// Default is TTL
+	NegativeTTL time.Duration
+
+	// Size is the maximum number of cached origins; the least recently used are evicted first
+	// Default is 10000
+	Size int
+}
+
+// CachedStore is an OriginStore that caches the answers of another store
+// Errors are not cached, and concurrent lookups of the same uncached origin share a single
+// call to the underlying store.
+type CachedStore struct {
+	store       OriginStore
+	ttl         time.Duration
+	negativeTTL time.Duration
+	size        int
+
+	// now returns the current time; tests replace it to expire entries
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+
+	// generation is incremented by Invalidate so lookups started before it do not cache
+	// their possibly stale answers
+	generation uint64
+
+	group singleflight.Group
+}
+
+// cacheEntry is the value of a CachedStore list element
+type cacheEntry struct {
+	origin  string
+	allowed bool
+	expires time.Time
+}
+
+// NewCachedStore wraps an OriginStore with a TTL and LRU cache
+func NewCachedStore(store OriginStore, config CacheConfig) *CachedStore {
+	s := &CachedStore{
+		store:       store,
+		ttl:         config.TTL,
+		negativeTTL: config.NegativeTTL,
+		size:        config.Size,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+	}
+	if s.ttl <= 0 {
+		s.ttl = time.Minute
+	}
+	if s.negativeTTL <= 0 {
+		s.negativeTTL = s.ttl
+	}
+	if s.size <= 0 {
+		s.size = 10000
+	}
+	return s
+}
+
+// Allowed implements OriginStore, answering from the cache when possible
+// Lookups run detached from ctx so one canceled request does not fail others waiting on the
+// same origin; ctx only bounds how long this caller waits, so the underlying store should
+// apply its own timeout.
+func (s *CachedStore) Allowed(ctx context.Context, origin string) (bool, error) {
+	if allowed, ok := s.get(origin); ok {
+		return allowed, nil
+	}
+
+	ch := s.group.DoChan(origin, func() (any, error) {
+		generation := s.currentGeneration()
+		allowed, err := s.store.Allowed(context.WithoutCancel(ctx), origin)
+		if err != nil {
+			return false, err
+		}
+		s.put(origin, allowed, generation)
+		return allowed, nil
+	})
+	select {
+	case result := <-ch:
+		if result.Err != nil {
+			return false, result.Err
+		}
+		return result.Val.(bool), nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// Invalidate removes an origin from the cache, e.g. after it was added to or removed from
+// the underlying store. Lookups already in progress are not cached, and later calls to
+// Allowed start a new lookup rather than waiting for them.
+func (s *CachedStore) Invalidate(origin string) {
+	if normalized, err := NormalizeOrigin(origin); err == nil {
+		origin = normalized
+	}
+	s.group.Forget(origin)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.generation++
+	if element, ok := s.entries[origin]; ok {
+		s.lru.Remove(element)
+		delete(s.entries, origin)
+	}
+}
+
+// get returns the cached answer for an origin if it has not expired
+func (s *CachedStore) get(origin string) (allowed, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.entries[origin]
+	if !ok {
+		return false, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if s.now().After(entry.expires) {
+		s.lru.Remove(element)
+		delete(s.entries, origin)
+		return false, false
+	}
+	s.lru.MoveToFront(element)
+	return entry.allowed, true
+}
+
+// currentGeneration returns the generation to pass to put for a lookup starting now
+func (s *CachedStore) currentGeneration() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.generation
+}
+
+// put caches the answer of a lookup started at generation, unless Invalidate was called
+// since, evicting the least recently used origin if the cache is full
+func (s *CachedStore) put(origin string, allowed bool, generation uint64) {
+	ttl := s.ttl
+	if !allowed {
+		ttl = s.negativeTTL
+	}
+	entry := &cacheEntry{origin: origin, allowed: allowed, expires: s.now().Add(ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if generation != s.generation {
+		return
+	}
+	if element, ok := s.entries[origin]; ok {
+		element.Value = entry
+		s.lru.MoveToFront(element)
+		return
+	}
+	s.entries[origin] = s.lru.PushFront(entry)
+	if s.lru.Len() > s.size {
+		oldest := s.lru.Back()
+		s.lru.Remove(oldest)
+		delete(s.entries, oldest.Value.(*cacheEntry).origin)
+	}
+}
+
+// MemoryStore is an OriginStore backed by an in-memory set of origins, useful for tests
+// and small deployments. The zero value allows no origins.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	origins map[string]bool
+}
+
+// NewMemoryStore creates a MemoryStore allowing the given origins
+// It returns a *ConfigError if any origin is invalid
+func NewMemoryStore(origins ...string) (*MemoryStore, error) {
+	s := &MemoryStore{origins: make(map[string]bool, len(origins))}
+	errs := &ConfigError{}
+	for _, origin := range origins {
+		if err := s.Add(origin); err != nil {
+			errs.add("origins", origin, err)
+		}
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add allows an origin
+func (s *MemoryStore) Add(origin string) error {
+	normalized, err := NormalizeOrigin(origin)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.origins == nil {
+		s.origins = make(map[string]bool)
+	}
+	s.origins[normalized] = true
+	return nil
+}
+
+// Remove stops allowing an origin
+func (s *MemoryStore) Remove(origin string) {
+	normalized, err := NormalizeOrigin(origin)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.origins, normalized)
+}
+
+// Allowed implements OriginStore
+func (s *MemoryStore) Allowed(_ context.Context, origin string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.origins[origin], nil
+}
@@ -0,0 +1,254 @@
+package cors
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// countingStore counts lookups and can block them until release is closed
+type countingStore struct {
+	OriginStore
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (s *countingStore) Allowed(ctx context.Context, origin string) (bool, error) {
+	s.calls.Add(1)
+	if s.release != nil {
+		<-s.release
+	}
+	if s.err != nil {
+		return false, s.err
+	}
+	return s.OriginStore.Allowed(ctx, origin)
+}
+
+func TestCorsOriginStore(t *testing.T) {
+	store, err := NewMemoryStore("https://tenant.customer.com", "https://Bücher.example:443")
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	counting := &countingStore{OriginStore: store}
+
+	app := fiber.New()
+	app.Use(New(Config{
+		AllowOrigins: "https://console.domain.com",
+		OriginStore:  counting,
+		AllowOriginsFunc: func(origin string, c *fiber.Ctx) (bool, error) {
+			return origin == "https://func.domain.com", nil
+		},
+	}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+		lookup  bool
+	}{
+		{origin: "https://console.domain.com", allowed: true},
+		{origin: "https://tenant.customer.com", allowed: true, lookup: true},
+		{origin: "https://TENANT.customer.com:443", allowed: true, lookup: true},
+		{origin: "https://xn--bcher-kva.example", allowed: true, lookup: true},
+		{origin: "https://func.domain.com", allowed: true, lookup: true},
+		{origin: "https://evil.com", lookup: true},
+		{origin: "not an origin"},
+		{origin: "null"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			before := counting.calls.Load()
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Origin", tt.origin)
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+
+			respOrigin := resp.Header.Get("Access-Control-Allow-Origin")
+			if tt.allowed && respOrigin != tt.origin {
+				t.Errorf("Expected Access-Control-Allow-Origin to be %q but got %q", tt.origin, respOrigin)
+			}
+			if !tt.allowed && respOrigin != "" {
+				t.Errorf("Expected no Access-Control-Allow-Origin header but got %q", respOrigin)
+			}
+			if looked := counting.calls.Load() > before; looked != tt.lookup {
+				t.Errorf("Expected store lookup to be %v but got %v", tt.lookup, looked)
+			}
+		})
+	}
+}
+
+func TestCorsOriginStoreError(t *testing.T) {
+	storeErr := errors.New("store unavailable")
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			if !errors.Is(err, storeErr) {
+				t.Errorf("Expected the store error but got %v", err)
+			}
+			return c.SendStatus(503)
+		},
+	})
+	app.Use(New(Config{OriginStore: &countingStore{err: storeErr}}))
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Origin", "https://tenant.customer.com")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != 503 {
+		t.Errorf("Expected status 503 but got %d", resp.StatusCode)
+	}
+}
+
+func TestCachedStore(t *testing.T) {
+	memory, _ := NewMemoryStore("https://a.com", "https://b.com", "https://c.com")
+	store := &countingStore{OriginStore: memory}
+	cache := NewCachedStore(store, CacheConfig{TTL: time.Minute, NegativeTTL: time.Second, Size: 2})
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	lookup := func(origin string, expected bool, calls int32) {
+		t.Helper()
+		allowed, err := cache.Allowed(ctx, origin)
+		if err != nil {
+			t.Fatalf("Expected no error but got %v", err)
+		}
+		if allowed != expected {
+			t.Errorf("Expected %s allowed to be %v but got %v", origin, expected, allowed)
+		}
+		if got := store.calls.Load(); got != calls {
+			t.Errorf("Expected %d store calls after looking up %s but got %d", calls, origin, got)
+		}
+	}
+
+	lookup("https://a.com", true, 1)
+	lookup("https://a.com", true, 1)
+
+	// Rejected origins are cached for NegativeTTL
+	lookup("https://evil.com", false, 2)
+	lookup("https://evil.com", false, 2)
+	now = now.Add(2 * time.Second)
+	lookup("https://evil.com", false, 3)
+	lookup("https://a.com", true, 3)
+
+	// Allowed origins expire after TTL
+	now = now.Add(time.Minute)
+	lookup("https://a.com", true, 4)
+
+	// Beyond Size the least recently used origin is evicted
+	lookup("https://b.com", true, 5) // cache: b.com, a.com
+	lookup("https://c.com", true, 6) // cache: c.com, b.com
+	lookup("https://a.com", true, 7)
+	lookup("https://c.com", true, 7)
+
+	// Invalidate drops a cached answer
+	memory.Remove("https://c.com")
+	cache.Invalidate("https://C.com:443")
+	lookup("https://c.com", false, 8)
+}
+
+func TestCachedStoreErrorsNotCached(t *testing.T) {
+	store := &countingStore{err: errors.New("store unavailable")}
+	cache := NewCachedStore(store, CacheConfig{})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.Allowed(context.Background(), "https://a.com"); err == nil {
+			t.Fatal("Expected an error but got nil")
+		}
+	}
+	if calls := store.calls.Load(); calls != 2 {
+		t.Errorf("Expected 2 store calls but got %d", calls)
+	}
+}
+
+func TestCachedStoreSingleflight(t *testing.T) {
+	memory, _ := NewMemoryStore("https://a.com")
+	store := &countingStore{OriginStore: memory, release: make(chan struct{})}
+	cache := NewCachedStore(store, CacheConfig{})
+
+	var started, wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		started.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			started.Done()
+			if allowed, err := cache.Allowed(context.Background(), "https://a.com"); !allowed || err != nil {
+				t.Errorf("Expected the origin to be allowed but got %v, %v", allowed, err)
+			}
+		}()
+	}
+	started.Wait()
+	time.Sleep(10 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	if calls := store.calls.Load(); calls != 1 {
+		t.Errorf("Expected concurrent lookups to share 1 store call but got %d", calls)
+	}
+}
+
+func TestCachedStoreInvalidateDuringLookup(t *testing.T) {
+	memory, _ := NewMemoryStore("https://a.com")
+	store := &countingStore{OriginStore: memory, release: make(chan struct{})}
+	cache := NewCachedStore(store, CacheConfig{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if allowed, err := cache.Allowed(context.Background(), "https://a.com"); !allowed || err != nil {
+			t.Errorf("Expected the origin to be allowed but got %v, %v", allowed, err)
+		}
+	}()
+	for store.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The answer of the lookup in progress predates Invalidate, so it must not be cached
+	cache.Invalidate("https://a.com")
+	close(store.release)
+	<-done
+
+	memory.Remove("https://a.com")
+	if allowed, err := cache.Allowed(context.Background(), "https://a.com"); allowed || err != nil {
+		t.Errorf("Expected the origin to be rejected but got %v, %v", allowed, err)
+	}
+	if calls := store.calls.Load(); calls != 2 {
+		t.Errorf("Expected 2 store calls but got %d", calls)
+	}
+}
+
+func TestCachedStoreCanceled(t *testing.T) {
+	store := &countingStore{OriginStore: &MemoryStore{}, release: make(chan struct{})}
+	defer close(store.release)
+	cache := NewCachedStore(store, CacheConfig{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := cache.Allowed(ctx, "https://a.com"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded but got %v", err)
+	}
+}
+
+func TestNewMemoryStoreWithError(t *testing.T) {
+	_, err := NewMemoryStore("https://a.com", "https://a.com/app")
+	if !errors.Is(err, ErrOriginPath) {
+		t.Errorf("Expected ErrOriginPath but got %v", err)
+	}
+}
@@ -0,0 +1,169 @@
+package cors
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gofiber/fiber/v2"
+)
+
+// reloadDelay is how long FileWatcher waits for events to stop before reading the file
+const reloadDelay = 100 * time.Millisecond
+
+// FileWatcher is a CORS middleware whose allowed origins are read from a plain text file and
+// reloaded whenever the file changes, without restarting the application
+type FileWatcher struct {
+	path    string
+	config  Config
+	dynamic *Dynamic
+	watcher *fsnotify.Watcher
+
+	// data is the file content the current policy was built from, so events that
+	// do not change it are ignored
+	data []byte
+
+	mu  sync.Mutex
+	err error
+
+	done chan struct{}
+}
+
+// WatchFile creates a CORS middleware that allows the origins listed in the file at path, one
+// per line, in addition to those in config. Blank lines and lines starting with # are ignored.
+//
+// The file is watched for changes, including being replaced by an editor or a Kubernetes
+// ConfigMap update. Once the file has been quiet for 100ms it is read and validated again;
+// if that fails the previous origins stay in effect and the error is returned by Err until
+// the next successful reload. WatchFile itself fails if the file cannot be read or is invalid.
+// Call Close to stop watching.
+func WatchFile(path string, config Config) (*FileWatcher, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &FileWatcher{path: path, config: config, dynamic: &Dynamic{}, done: make(chan struct{})}
+	if err := w.reload(); err != nil {
+		return nil, err
+	}
+
+	// Watch the directory rather than the file, since replacing the file ends a watch on it
+	w.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.watcher.Add(filepath.Dir(path)); err != nil {
+		w.watcher.Close()
+		return nil, err
+	}
+	go w.watch()
+
+	return w, nil
+}
+
+// Handler returns the middleware handler, which applies the most recently loaded origins
+func (w *FileWatcher) Handler() fiber.Handler {
+	return w.dynamic.Handler()
+}
+
+// Err returns the error from the last reload, or nil if it succeeded
+func (w *FileWatcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Close stops watching the file; the handler keeps the origins loaded last
+func (w *FileWatcher) Close() error {
+	err := w.watcher.Close()
+	<-w.done
+	return err
+}
+
+// watch reloads the file when it changes until the watcher is closed.
+// Writers such as shell redirection truncate the file before writing it, so the file is read
+// once events have stopped for reloadDelay rather than on the first one.
+func (w *FileWatcher) watch() {
+	defer close(w.done)
+	timer := time.NewTimer(reloadDelay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 && w.watched(event.Name) {
+				timer.Reset(reloadDelay)
+			}
+		case <-timer.C:
+			w.setErr(w.reload())
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.setErr(err)
+		}
+	}
+}
+
+// watched reports whether a change to the named file in the directory may change the
+// watched file: the file itself, or the ..data symlink Kubernetes swaps to update ConfigMaps
+func (w *FileWatcher) watched(name string) bool {
+	base := filepath.Base(name)
+	return base == filepath.Base(w.path) || base == "..data"
+}
+
+func (w *FileWatcher) setErr(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.err = err
+}
+
+// reload reads the file and updates the policy if its content changed
+func (w *FileWatcher) reload() error {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if w.data != nil && bytes.Equal(data, w.data) {
+		return nil
+	}
+
+	config := w.config
+	config.Origins = append(configOrigins(w.config), parseOriginFile(data)...)
+	if err := w.dynamic.Update(config); err != nil {
+		return fmt.Errorf("%s: %w", w.path, err)
+	}
+	w.data = data
+	return nil
+}
+
+// configOrigins returns the origins configured by Origins or, if it is empty, AllowOrigins
+func configOrigins(config Config) []string {
+	if len(config.Origins) > 0 {
+		return trimList(config.Origins)
+	}
+	return splitList(config.AllowOrigins)
+}
+
+// parseOriginFile returns the origins listed in an allowlist file
+func parseOriginFile(data []byte) []string {
+	var origins []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			origins = append(origins, line)
+		}
+	}
+	return origins
+}
@@ -0,0 +1,154 @@
+package cors
+
+import (
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "origins.txt")
+	writeFile := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("# customer origins\nhttps://a.customer.com\n\n")
+
+	w, err := WatchFile(path, Config{AllowOrigins: "https://console.domain.com"})
+	if err != nil {
+		t.Fatalf("Expected no error but got %v", err)
+	}
+	defer w.Close()
+
+	app := fiber.New()
+	app.Use(w.Handler())
+	app.Get("/", func(c *fiber.Ctx) error {
+		return c.SendStatus(200)
+	})
+
+	allowed := func(origin string) bool {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Origin", origin)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		return resp.Header.Get("Access-Control-Allow-Origin") == origin
+	}
+	eventually := func(condition func() bool, message string) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatal(message)
+			}
+		}
+	}
+
+	if !allowed("https://console.domain.com") || !allowed("https://a.customer.com") {
+		t.Fatal("Expected the configured and listed origins to be allowed")
+	}
+	if allowed("https://b.customer.com") {
+		t.Fatal("Expected an unlisted origin to be rejected")
+	}
+
+	writeFile("https://a.customer.com\nhttps://b.customer.com\n")
+	eventually(func() bool { return allowed("https://b.customer.com") }, "Expected an added origin to be allowed")
+
+	// Rewriting the file in place briefly leaves it empty, which must not be loaded
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := f.WriteString("https://a.customer.com\nhttps://b.customer.com\nhttps://d.customer.com\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); !allowed("https://d.customer.com"); time.Sleep(5 * time.Millisecond) {
+		if !allowed("https://a.customer.com") {
+			t.Fatal("Expected the listed origins to stay allowed while the file is rewritten")
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the rewritten file to be loaded")
+		}
+	}
+
+	// An invalid file keeps the last good origins
+	writeFile("https://b.customer.com/app\n")
+	eventually(func() bool { return w.Err() != nil }, "Expected an error for an invalid file")
+	if !errors.Is(w.Err(), ErrOriginPath) {
+		t.Errorf("Expected ErrOriginPath but got %v", w.Err())
+	}
+	if !allowed("https://b.customer.com") {
+		t.Error("Expected the last good origins to stay allowed")
+	}
+
+	// Replacing the file, as editors do, is picked up too
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte("https://c.customer.com\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	eventually(func() bool { return allowed("https://c.customer.com") }, "Expected the replaced file to be loaded")
+	if allowed("https://b.customer.com") {
+		t.Error("Expected a removed origin to be rejected")
+	}
+	if w.Err() != nil {
+		t.Errorf("Expected no error after a successful reload but got %v", w.Err())
+	}
+}
+
+func TestWatchFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := WatchFile(filepath.Join(dir, "missing.txt"), Config{}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist but got %v", err)
+	}
+
+	path := filepath.Join(dir, "origins.txt")
+	if err := os.WriteFile(path, []byte("ftp//bad\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WatchFile(path, Config{}); !errors.Is(err, ErrInvalidOrigin) {
+		t.Errorf("Expected ErrInvalidOrigin but got %v", err)
+	}
+}
+
+func TestParseOriginFile(t *testing.T) {
+	origins := parseOriginFile([]byte("# comment\n  https://a.com  \r\n\nhttps://b.com"))
+	if len(origins) != 2 || origins[0] != "https://a.com" || origins[1] != "https://b.com" {
+		t.Errorf("Expected [https://a.com https://b.com] but got %v", origins)
+	}
+}
+
+func TestFileWatcherWatched(t *testing.T) {
+	w := &FileWatcher{path: "/etc/cors/origins.txt"}
+
+	tests := []struct {
+		name    string
+		watched bool
+	}{
+		{"/etc/cors/origins.txt", true},
+		{"/etc/cors/..data", true},
+		{"/etc/cors/origins.txt.tmp", false},
+		{"/etc/cors/other.txt", false},
+	}
+
+	for _, tt := range tests {
+		if watched := w.watched(tt.name); watched != tt.watched {
+			t.Errorf("watched(%q) = %v, expected %v", tt.name, watched, tt.watched)
+		}
+	}
+}